@@ -2,6 +2,9 @@
 # Демонстрационный скрипт для тестирования антиплагиата
 set -e
 
+# Gateway должен быть запущен с ключами:
+# API_KEYS="ivanov-key=Иванов,petrov-key=Петров,sidorov-key=Сидоров,teacher-key=Преподаватель:teacher"
+
 # Файл для отправки
 TESTDIR="demo_test_files"
 mkdir -p $TESTDIR
@@ -10,17 +13,22 @@ echo "Мама мыла раму" > $TESTDIR/ivanov_hw1.txt
 echo "Мама мыла раму" > $TESTDIR/petrov_hw1.txt
 echo "Совсем другой текст" > $TESTDIR/sidorov_hw1.txt
 
-echo "--- 1. Иванов сдаёт оригинал (hw1) ---"
-curl -F "file=@$TESTDIR/ivanov_hw1.txt" -F "sender=Иванов" -F "work_id=hw1" http://localhost:8000/api/submit
+echo "--- 0. Преподаватель создаёт задание hw1 ---"
+curl -H "X-API-Key: teacher-key" -H "Content-Type: application/json" \
+  -d '{"id":"hw1","title":"Домашнее задание 1","late_policy":"flag"}' http://localhost:8000/api/works
+
+echo -e "\n--- 1. Иванов сдаёт оригинал (hw1) ---"
+curl -F "file=@$TESTDIR/ivanov_hw1.txt" -H "X-API-Key: ivanov-key" -F "work_id=hw1" http://localhost:8000/api/submit
 
 echo -e "\n--- 2. Петров сдаёт такую же работу (hw1) ---"
-curl -F "file=@$TESTDIR/petrov_hw1.txt" -F "sender=Петров" -F "work_id=hw1" http://localhost:8000/api/submit
+curl -F "file=@$TESTDIR/petrov_hw1.txt" -H "X-API-Key: petrov-key" -F "work_id=hw1" http://localhost:8000/api/submit
 
 echo -e "\n--- 3. Сидоров сдаёт другую работу (hw1) ---"
-curl -F "file=@$TESTDIR/sidorov_hw1.txt" -F "sender=Сидоров" -F "work_id=hw1" http://localhost:8000/api/submit
+curl -F "file=@$TESTDIR/sidorov_hw1.txt" -H "X-API-Key: sidorov-key" -F "work_id=hw1" http://localhost:8000/api/submit
 
 echo -e "\n--- 4. Отчёт по hw1 (ожидание флагов плагиата) ---"
-curl http://localhost:8000/api/works/hw1/reports | jq .
+sleep 5
+curl -H "X-API-Key: teacher-key" http://localhost:8000/api/works/hw1/reports | jq .
 
 # cleanup
 rm -rf $TESTDIR
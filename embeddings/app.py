@@ -1,16 +1,21 @@
 from flask import Flask, request, jsonify
 from sentence_transformers import SentenceTransformer
 import logging
+import os
 
 app = Flask(__name__)
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
 
 # Загружаем модель при старте
-logger.info("Loading sentence-transformers/all-MiniLM-L6-v2...")
-model = SentenceTransformer('all-MiniLM-L6-v2')
+MODEL_NAME = os.environ.get('EMBEDDING_MODEL', 'all-MiniLM-L6-v2')
+logger.info(f"Loading sentence-transformers/{MODEL_NAME}...")
+model = SentenceTransformer(MODEL_NAME)
 logger.info("Model loaded successfully")
 
+# Ограничение размера пакета, чтобы один запрос не занял сервис надолго
+MAX_BATCH_SIZE = int(os.environ.get('MAX_BATCH_SIZE', '256'))
+
 
 @app.route('/embed', methods=['POST'])
 def embed():
@@ -41,6 +46,80 @@ def embed():
         return jsonify({"error": str(e)}), 500
 
 
+@app.route('/embed/batch', methods=['POST'])
+def embed_batch():
+    """
+    Генерирует эмбединги для пакета текстов за один проход модели.
+
+    Ожидает JSON: {"texts": ["первый текст", "второй текст"]}
+    Возвращает: {"embeddings": [[0.1, ...], [0.2, ...]]} — в том же порядке
+    """
+    try:
+        data = request.get_json()
+
+        if not data or 'texts' not in data:
+            return jsonify({"error": "Missing 'texts' field"}), 400
+
+        texts = data['texts']
+
+        if not isinstance(texts, list) or not texts or not all(isinstance(t, str) and t.strip() for t in texts):
+            return jsonify({"error": "Texts must be a non-empty list of non-empty strings"}), 400
+
+        if len(texts) > MAX_BATCH_SIZE:
+            return jsonify({"error": f"Batch too large: {len(texts)} texts, at most {MAX_BATCH_SIZE}"}), 413
+
+        embeddings = model.encode(texts, batch_size=min(len(texts), 64)).tolist()
+
+        return jsonify({"embeddings": embeddings}), 200
+
+    except Exception as e:
+        logger.error(f"Error in /embed/batch: {e}")
+        return jsonify({"error": str(e)}), 500
+
+
+@app.route('/v1/embeddings', methods=['POST'])
+def openai_embeddings():
+    """
+    OpenAI-совместимый эндпоинт, чтобы проверять провайдер "openai" без внешнего сервера.
+
+    Ожидает JSON: {"model": "all-MiniLM-L6-v2", "input": "текст" или ["текст", ...]}
+    Возвращает: {"object": "list", "data": [{"object": "embedding", "index": 0, "embedding": [...]}], ...}
+    """
+    try:
+        data = request.get_json()
+
+        if not data or 'input' not in data:
+            return jsonify({"error": {"message": "Missing 'input' field"}}), 400
+
+        inputs = data['input']
+        if isinstance(inputs, str):
+            inputs = [inputs]
+
+        if not isinstance(inputs, list) or not inputs or not all(isinstance(t, str) and t.strip() for t in inputs):
+            return jsonify({"error": {"message": "Input must be a non-empty string or list of strings"}}), 400
+
+        if len(inputs) > MAX_BATCH_SIZE:
+            return jsonify({"error": {"message": f"Batch too large: at most {MAX_BATCH_SIZE} inputs"}}), 413
+
+        model_name = data.get('model', MODEL_NAME)
+        if model_name != MODEL_NAME:
+            return jsonify({"error": {"message": f"Model '{model_name}' is not loaded, use '{MODEL_NAME}'"}}), 400
+
+        embeddings = model.encode(inputs).tolist()
+        tokens = sum(len(t.split()) for t in inputs)
+
+        return jsonify({
+            "object": "list",
+            "data": [{"object": "embedding", "index": i, "embedding": e} for i, e in enumerate(embeddings)],
+            "model": MODEL_NAME,
+            "usage": {"prompt_tokens": tokens, "total_tokens": tokens},
+        }), 200
+
+    except Exception as e:
+        logger.error(f"Error in /v1/embeddings: {e}")
+        return jsonify({"error": {"message": str(e)}}), 500
+
+
 @app.route('/health', methods=['GET'])
 def health():
     """Health check для Docker"""
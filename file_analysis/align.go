@@ -0,0 +1,291 @@
+package main
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+const (
+	alignSeedWords   = 3  // длина k-граммы-затравки в словах
+	alignMaxGap      = 8  // допустимая вставка/пропуск между затравками, в словах
+	alignMinWords    = 8  // минимальная длина выравненного фрагмента
+	alignMaxSeedFreq = 20 // слишком частые k-граммы (шаблонные фразы) не используются как затравки
+	alignPadding     = alignMaxGap
+	alignMaxCells    = 4000000
+)
+
+type AlignmentSide struct {
+	Start    int       `json:"start"`
+	End      int       `json:"end"`
+	Text     string    `json:"text"`
+	Location *Location `json:"location,omitempty"`
+}
+
+// AlignedSegment — пара совпавших фрагментов; Score — доля совпавших слов
+type AlignedSegment struct {
+	A     AlignmentSide `json:"a"`
+	B     AlignmentSide `json:"b"`
+	Words int           `json:"words"`
+	Score float64       `json:"score"`
+}
+
+type AlignedDocument struct {
+	ID       string  `json:"id"`
+	Sender   string  `json:"sender"`
+	WorkID   string  `json:"work_id"`
+	FileName string  `json:"file_name"`
+	Length   int     `json:"length"`
+	Coverage float64 `json:"coverage"`
+	Text     string  `json:"text,omitempty"`
+}
+
+type Alignment struct {
+	A        AlignedDocument  `json:"a"`
+	B        AlignedDocument  `json:"b"`
+	Segments []AlignedSegment `json:"segments"`
+}
+
+type alignRegion struct {
+	aStart, aEnd int // индексы токенов, конец не включается
+	bStart, bEnd int
+	lastI, lastJ int
+	words        int
+}
+
+// alignTexts находит локальные выравнивания совпадающих фрагментов двух текстов:
+// общие k-граммы слов служат затравками, близкие затравки на почти одной диагонали
+// сцепляются в регионы, а границы регионов уточняются алгоритмом Смита-Уотермана.
+func alignTexts(a, b string) []AlignedSegment {
+	_, spansA := detectLanguages(a)
+	_, spansB := detectLanguages(b)
+	ta, tb := stemTokens(tokenize(a), spansA), stemTokens(tokenize(b), spansB)
+	regions := chainSeeds(ta, tb)
+
+	var refined []alignRegion
+	for _, r := range regions {
+		if r, ok := refineRegion(ta, tb, r); ok && r.words >= alignMinWords {
+			refined = append(refined, r)
+		}
+	}
+
+	// Жадно оставляем самые длинные регионы, не пересекающиеся ни в одном из документов
+	sort.Slice(refined, func(i, j int) bool { return refined[i].words > refined[j].words })
+	var kept []alignRegion
+	for _, r := range refined {
+		overlaps := false
+		for _, k := range kept {
+			if r.aStart < k.aEnd && k.aStart < r.aEnd || r.bStart < k.bEnd && k.bStart < r.bEnd {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, r)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].aStart < kept[j].aStart })
+
+	ra, rb := []rune(a), []rune(b)
+	segments := make([]AlignedSegment, 0, len(kept))
+	for _, r := range kept {
+		as, ae := ta[r.aStart].Start, ta[r.aEnd-1].End
+		bs, be := tb[r.bStart].Start, tb[r.bEnd-1].End
+		segments = append(segments, AlignedSegment{
+			A:     AlignmentSide{Start: as, End: ae, Text: string(ra[as:ae])},
+			B:     AlignmentSide{Start: bs, End: be, Text: string(rb[bs:be])},
+			Words: r.words,
+			Score: float64(r.words) / float64(max(r.aEnd-r.aStart, r.bEnd-r.bStart)),
+		})
+	}
+	return segments
+}
+
+func gramHash(tokens []Token) uint64 {
+	h := fnv.New64a()
+	for _, t := range tokens {
+		h.Write([]byte(t.Text))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+func chainSeeds(ta, tb []Token) []alignRegion {
+	k := alignSeedWords
+	if len(ta) < k || len(tb) < k {
+		return nil
+	}
+
+	index := make(map[uint64][]int)
+	for j := 0; j+k <= len(tb); j++ {
+		h := gramHash(tb[j : j+k])
+		index[h] = append(index[h], j)
+	}
+
+	var open, closed []alignRegion
+	for i := 0; i+k <= len(ta); i++ {
+		positions := index[gramHash(ta[i:i+k])]
+		if len(positions) > alignMaxSeedFreq {
+			continue
+		}
+
+		// Регионы, от которых затравка ушла дальше допустимого разрыва, закрываются
+		still := open[:0]
+		for _, r := range open {
+			if i-r.aEnd > alignMaxGap {
+				closed = append(closed, r)
+			} else {
+				still = append(still, r)
+			}
+		}
+		open = still
+
+		for _, j := range positions {
+			best := -1
+			bestShift := alignMaxGap + 1
+			for idx, r := range open {
+				if j <= r.lastJ || j-r.bEnd > alignMaxGap {
+					continue
+				}
+				shift := (j - i) - (r.lastJ - r.lastI)
+				if shift < 0 {
+					shift = -shift
+				}
+				if shift < bestShift {
+					best, bestShift = idx, shift
+				}
+			}
+
+			if best >= 0 {
+				r := &open[best]
+				r.aEnd = max(r.aEnd, i+k)
+				r.bEnd = max(r.bEnd, j+k)
+				r.lastI, r.lastJ = i, j
+			} else {
+				open = append(open, alignRegion{
+					aStart: i, aEnd: i + k,
+					bStart: j, bEnd: j + k,
+					lastI: i, lastJ: j,
+				})
+			}
+		}
+	}
+	return append(closed, open...)
+}
+
+// refineRegion уточняет регион локальным выравниванием Смита-Уотермана по словам
+func refineRegion(ta, tb []Token, r alignRegion) (alignRegion, bool) {
+	as, ae := max(r.aStart-alignPadding, 0), min(r.aEnd+alignPadding, len(ta))
+	bs, be := max(r.bStart-alignPadding, 0), min(r.bEnd+alignPadding, len(tb))
+	n, m := ae-as, be-bs
+	if n*m > alignMaxCells {
+		r.words = min(r.aEnd-r.aStart, r.bEnd-r.bStart)
+		return r, true
+	}
+
+	const (
+		matchScore = 2
+		mismatch   = -1
+		gap        = -1
+	)
+	const (
+		dirNone = iota
+		dirDiag
+		dirUp
+		dirLeft
+	)
+
+	score := make([]int, (n+1)*(m+1))
+	dir := make([]uint8, (n+1)*(m+1))
+	bestScore, bestI, bestJ := 0, 0, 0
+
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			s := mismatch
+			if ta[as+i-1].Text == tb[bs+j-1].Text {
+				s = matchScore
+			}
+			cell, d := 0, uint8(dirNone)
+			if v := score[(i-1)*(m+1)+j-1] + s; v > cell {
+				cell, d = v, dirDiag
+			}
+			if v := score[(i-1)*(m+1)+j] + gap; v > cell {
+				cell, d = v, dirUp
+			}
+			if v := score[i*(m+1)+j-1] + gap; v > cell {
+				cell, d = v, dirLeft
+			}
+			score[i*(m+1)+j], dir[i*(m+1)+j] = cell, d
+			if cell > bestScore {
+				bestScore, bestI, bestJ = cell, i, j
+			}
+		}
+	}
+	if bestScore == 0 {
+		return r, false
+	}
+
+	i, j, words := bestI, bestJ, 0
+	for i > 0 && j > 0 && score[i*(m+1)+j] > 0 {
+		switch dir[i*(m+1)+j] {
+		case dirDiag:
+			if ta[as+i-1].Text == tb[bs+j-1].Text {
+				words++
+			}
+			i, j = i-1, j-1
+		case dirUp:
+			i--
+		case dirLeft:
+			j--
+		default:
+			i, j = 0, 0
+		}
+	}
+
+	return alignRegion{
+		aStart: as + i, aEnd: as + bestI,
+		bStart: bs + j, bEnd: bs + bestJ,
+		words: words,
+	}, true
+}
+
+// alignSubmissions строит выравнивание двух сохранённых сдач
+func alignSubmissions(a, b Submission, withText bool) Alignment {
+	segments := alignTexts(a.Text, b.Text)
+	for i := range segments {
+		segments[i].A.Location = locate(a.Blocks, segments[i].A.Start)
+		segments[i].B.Location = locate(b.Blocks, segments[i].B.Start)
+	}
+
+	result := Alignment{
+		A:        alignedDocument(a, withText),
+		B:        alignedDocument(b, withText),
+		Segments: segments,
+	}
+
+	var coveredA, coveredB int
+	for _, s := range segments {
+		coveredA += s.A.End - s.A.Start
+		coveredB += s.B.End - s.B.Start
+	}
+	if result.A.Length > 0 {
+		result.A.Coverage = float64(coveredA) / float64(result.A.Length)
+	}
+	if result.B.Length > 0 {
+		result.B.Coverage = float64(coveredB) / float64(result.B.Length)
+	}
+	return result
+}
+
+func alignedDocument(sub Submission, withText bool) AlignedDocument {
+	doc := AlignedDocument{
+		ID:       sub.ID,
+		Sender:   sub.Sender,
+		WorkID:   sub.WorkID,
+		FileName: sub.FileName,
+		Length:   len([]rune(sub.Text)),
+	}
+	if withText {
+		doc.Text = sub.Text
+	}
+	return doc
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// AnalysisRequest — тело запроса /analyze
+type AnalysisRequest struct {
+	FileName   string        `json:"file_name"`   // имя от клиента: по нему определяется формат, оно попадает в отчёт
+	StoredName string        `json:"stored_name"` // уникальное имя файла в /files
+	Sender     string        `json:"sender"`      // subject отправителя, по нему определяются чужие сдачи
+	SenderName string        `json:"sender_name"`
+	WorkID     string        `json:"work_id"`
+	Late       bool          `json:"late"`
+	Settings   *WorkSettings `json:"settings"`
+}
+
+// analyzeSubmission прогоняет извлечённый текст работы через включённые в задании анализаторы
+func analyzeSubmission(req AnalysisRequest, doc Document) (Report, error) {
+	obfuscation := normalizeDocument(&doc)
+	text := doc.Text
+	docID := documentID(req.Sender, req.WorkID, req.FileName)
+	thresholds := resolveThresholds(req.Settings)
+
+	report := Report{
+		ID:         fmt.Sprintf("%d", docID),
+		FileName:   req.FileName,
+		Sender:     req.Sender,
+		SenderName: req.SenderName,
+		WorkID:     req.WorkID,
+		Late:       req.Late,
+		Format:     doc.Format,
+		Encoding:   doc.Encoding,
+		Sections:   doc.Sections,
+		Verdict:    VerdictClean,
+		Thresholds: &thresholds,
+		Timestamp:  getCurrentTime(),
+	}
+	if obfuscation.Detected {
+		report.Obfuscation = &obfuscation
+	}
+
+	err := saveSubmission(Submission{
+		ID:         report.ID,
+		Sender:     req.Sender,
+		WorkID:     req.WorkID,
+		FileName:   req.FileName,
+		Timestamp:  report.Timestamp.Format(time.RFC3339),
+		Text:       text,
+		Blocks:     doc.Blocks,
+		CodeBlocks: doc.CodeBlocks,
+	})
+	if err != nil {
+		return report, err
+	}
+
+	// Исходный код проверяется только по нормализованным токенам: эмбеддинги предложений,
+	// словесные отпечатки и облако слов для него бессмысленны
+	if lang := req.Settings.codeLanguage(req.FileName); lang != "" && doc.Format != "ipynb" {
+		report.Mode = "code"
+		report.CodeLanguage = lang
+		if req.Settings.enabled("code") {
+			if err := analyzeCode(&report, text, lang, thresholds); err != nil {
+				return report, err
+			}
+			locateCodeRegions(&report, nil)
+		}
+		if lang == "go" && req.Settings.enabled("structure") {
+			if err := analyzeStructure(&report, text, thresholds); err != nil {
+				return report, err
+			}
+		}
+		scoreReport(&report, thresholds)
+		return report, nil
+	}
+	report.Mode = "text"
+
+	// В блокноте Jupyter ячейки кода проверяются анализаторами кода, markdown-ячейки — как
+	// обычный текст, а выводы ячеек не учитываются вовсе
+	if doc.Format == "ipynb" {
+		report.Mode = "notebook"
+		report.CodeLanguage = doc.CodeLanguage
+		if doc.CodeLanguage != "" && req.Settings.enabled("code") {
+			if err := analyzeCode(&report, doc.Code, doc.CodeLanguage, thresholds); err != nil {
+				return report, err
+			}
+			locateCodeRegions(&report, doc.CodeBlocks)
+		}
+	}
+	prose := strings.TrimSpace(text) != ""
+
+	// Стоп-слова и стеммеры выбираются по языку каждого участка текста
+	language, spans := detectLanguages(text)
+	report.Language = language
+	if languages := spanLanguages(spans); len(languages) > 1 {
+		report.Languages = languages
+	}
+
+	if prose && req.Settings.enabled("embedding") {
+		if err := analyzeEmbedding(&report, docID, text, language, thresholds); err != nil {
+			return report, err
+		}
+	}
+
+	if prose && req.Settings.enabled("lexical") {
+		if err := analyzeLexical(&report, text, spans, thresholds); err != nil {
+			return report, err
+		}
+	}
+
+	if prose && req.Settings.enabled("minhash") {
+		if err := analyzeMinHash(&report, text, thresholds); err != nil {
+			return report, err
+		}
+	}
+
+	locatePassages(&report, doc.Blocks)
+
+	if prose && req.Settings.enabled("wordcloud") {
+		wordCloud, err := downloadAndSaveWordCloud(text, spans)
+		if err != nil {
+			log.Printf("Error generating word cloud: %v", err)
+			report.Error = "Failed to generate word cloud"
+		} else {
+			report.WordCloud = wordCloud
+		}
+	}
+
+	scoreReport(&report, thresholds)
+	return report, nil
+}
+
+// flagObfuscation: подмена букв и невидимые символы сами по себе говорят об умысле, поэтому
+// такая работа считается как минимум подозрительной независимо от найденных совпадений
+func flagObfuscation(report *Report) {
+	if report.Obfuscation != nil && report.Verdict == VerdictClean {
+		report.Verdict = VerdictSuspicious
+	}
+}
+
+func analyzeEmbedding(report *Report, docID int64, text, language string, thresholds Thresholds) error {
+	chunks, stored, method := embedChunks(report.FileName, text, language)
+	report.EmbeddingMethod = method
+	// Косинус хеширующих векторов ниже, чем у модели, поэтому и порог поиска у него свой
+	thresholds = thresholds.forEmbedding(method)
+
+	if err := storeChunks(docID, report.Sender, report.WorkID, report.FileName, chunks, stored); err != nil {
+		return err
+	}
+
+	matches, err := findSimilarChunks(report.WorkID, report.Sender, chunks, method, stored[method], thresholds)
+	if err != nil {
+		return fmt.Errorf("failed to find similar documents: %w", err)
+	}
+
+	report.Matches = matches
+	return nil
+}
+
+// embedChunks режет текст на фрагменты и считает их векторы по именам: хеширующий всегда,
+// модели — если сервис эмбеддингов доступен. method — вектор, по которому искать.
+func embedChunks(fileName, text, language string) (chunks []Chunk, vectors map[string][][]float32, method string) {
+	chunks = splitChunks(text, config.ChunkWords, config.ChunkOverlap)
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		// Язык фрагмента сохраняется в хранилище векторов; короткий фрагмент считается написанным на основном языке
+		if chunks[i].Language = detectLanguage(chunk.Text); chunks[i].Language == "" {
+			chunks[i].Language = language
+		}
+		texts[i] = chunk.Text
+	}
+
+	// Хеширующий вектор сохраняется всегда, чтобы работы, проверенные без модели, сравнивались
+	// со всеми остальными; если сервис эмбеддингов недоступен, поиск идёт по нему
+	hashing := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		hashing[i] = hashingVector(chunk.Text, chunk.Language, config.FallbackDim)
+	}
+	vectors = map[string][][]float32{hashingVectorName: hashing}
+
+	model, err := generateVectors(texts)
+	if err != nil {
+		log.Printf("Embeddings unavailable, using hashing vectors for %s: %v", fileName, err)
+		return chunks, vectors, hashingVectorName
+	}
+	vectors[modelVectorName] = model
+	return chunks, vectors, modelVectorName
+}
+
+// embeddingSimilarity — лучшая оценка среди совпадений с достаточным покрытием: один общий
+// фрагмент (условие задания, титульный лист) не должен делать плагиатом всю работу.
+// Совпадение короче MIN_COVERAGE работы остаётся в matches, но в оценку не входит.
+func embeddingSimilarity(matches []Match) float64 {
+	var best float64
+	for _, m := range matches {
+		if m.Coverage >= config.MinCoverage {
+			best = max(best, m.Score)
+		}
+	}
+	return best
+}
+
+func analyzeLexical(report *Report, text string, spans []languageSpan, thresholds Thresholds) error {
+	rec := fingerprintRecord{
+		SubmissionID: report.ID,
+		Sender:       report.Sender,
+		WorkID:       report.WorkID,
+		FileName:     report.FileName,
+		Timestamp:    report.Timestamp.Format(time.RFC3339),
+		Version:      lexicalFingerprintVersion,
+		Fingerprints: lexicalFingerprints(text, spans),
+	}
+
+	if err := saveFingerprints(config.FingerprintDir, rec); err != nil {
+		return err
+	}
+
+	matches, err := findLexicalMatches(rec, thresholds.SearchLimit)
+	if err != nil {
+		return fmt.Errorf("failed to find lexical matches: %w", err)
+	}
+
+	report.LexicalMatches = matches
+	if len(matches) > 0 {
+		report.LexicalOverlap = matches[0].Overlap
+	}
+	return nil
+}
+
+func analyzeCode(report *Report, text, lang string, thresholds Thresholds) error {
+	fingerprints, err := codeFingerprintsCached(text, lang)
+	if err != nil {
+		return err
+	}
+
+	rec := fingerprintRecord{
+		SubmissionID: report.ID,
+		Sender:       report.Sender,
+		WorkID:       report.WorkID,
+		FileName:     report.FileName,
+		Timestamp:    report.Timestamp.Format(time.RFC3339),
+		Fingerprints: fingerprints,
+	}
+
+	if err := saveFingerprints(config.CodeFingerprintDir, rec); err != nil {
+		return err
+	}
+
+	matches, err := findCodeMatches(rec, thresholds.SearchLimit)
+	if err != nil {
+		return fmt.Errorf("failed to find code matches: %w", err)
+	}
+
+	report.CodeMatches = matches
+	if len(matches) > 0 {
+		report.CodeOverlap = matches[0].Overlap
+	}
+	return nil
+}
+
+// analyzeStructure сравнивает Go-программы по формам синтаксических деревьев функций.
+// Файл, который не удаётся разобрать, просто не получает структурной оценки.
+func analyzeStructure(report *Report, text string, thresholds Thresholds) error {
+	functions, err := goFunctionShapes(text)
+	if err != nil {
+		log.Printf("Skipping structural analysis of %s: %v", report.FileName, err)
+		return nil
+	}
+
+	rec := structureRecord{
+		SubmissionID: report.ID,
+		Sender:       report.Sender,
+		WorkID:       report.WorkID,
+		FileName:     report.FileName,
+		Timestamp:    report.Timestamp.Format(time.RFC3339),
+		Functions:    functions,
+	}
+
+	if err := saveWorkRecord(config.StructureDir, rec.WorkID, rec.SubmissionID, rec); err != nil {
+		return err
+	}
+
+	matches, err := findStructuralMatches(rec, thresholds.SearchLimit)
+	if err != nil {
+		return fmt.Errorf("failed to find structural matches: %w", err)
+	}
+
+	report.StructuralMatches = matches
+	if len(matches) > 0 {
+		report.StructuralScore = matches[0].Score
+	}
+	return nil
+}
+
+// analyzeMinHash ищет почти-дубликаты по LSH-индексу среди сдач того же задания
+// (или всего корпуса при MINHASH_SCOPE=corpus) и добавляет оценку Jaccard в общий список совпадений. Jaccard по шинглам из 3 слов
+// строже косинуса эмбеддингов, поэтому в scoreReport он сравнивается с теми же порогами.
+func analyzeMinHash(report *Report, text string, thresholds Thresholds) error {
+	sig := minhashSignature(tokenize(text))
+	if sig == nil {
+		return nil
+	}
+
+	corpus := config.MinHashScope == minhashScopeCorpus
+	candidates := minhashIndex.Query(sig, report.WorkID, corpus, report.Sender, config.MinHashThreshold, thresholds.SearchLimit)
+
+	err := minhashIndex.Add(minhashEntry{
+		ID:        report.ID,
+		Sender:    report.Sender,
+		WorkID:    report.WorkID,
+		FileName:  report.FileName,
+		Timestamp: report.Timestamp.Format(time.RFC3339),
+		Signature: sig,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range candidates {
+		merged := false
+		for i := range report.Matches {
+			if report.Matches[i].ID == c.Entry.ID {
+				report.Matches[i].Jaccard = c.Jaccard
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			report.Matches = append(report.Matches, Match{
+				ID:        c.Entry.ID,
+				FileName:  c.Entry.FileName,
+				Sender:    c.Entry.Sender,
+				WorkID:    c.Entry.WorkID,
+				Jaccard:   c.Jaccard,
+				Timestamp: c.Entry.Timestamp,
+			})
+		}
+	}
+	sortMatches(report.Matches)
+	return nil
+}
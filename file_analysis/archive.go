@@ -0,0 +1,275 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var errUnsafeArchive = errors.New("unsafe archive")
+
+// Расширения документов, которые проверяются внутри архива; исходный код определяется
+// по codeExtensions
+var archiveDocumentExtensions = map[string]bool{
+	".txt": true, ".pdf": true, ".docx": true, ".odt": true, ".pptx": true, ".rtf": true,
+	".tex": true, ".latex": true, ".md": true, ".markdown": true, ".ipynb": true,
+}
+
+// archiveEntry — файл проекта, извлечённый из архива
+type archiveEntry struct {
+	Path string
+	Data []byte
+}
+
+// archiveLimiter следит за ограничениями распаковки: числом записей, общим распакованным
+// размером и степенью сжатия (защита от zip-бомб)
+type archiveLimiter struct {
+	packed  int64
+	entries int
+	total   int64
+}
+
+func isArchive(fileName string) bool {
+	name := strings.ToLower(fileName)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func archiveFormat(fileName string) string {
+	if strings.HasSuffix(strings.ToLower(fileName), ".zip") {
+		return "zip"
+	}
+	return "tar"
+}
+
+// archivePath нормализует имя записи архива и отклоняет абсолютные пути и выход
+// за пределы архива через ".."
+func archivePath(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || len(name) > 1 && name[1] == ':' {
+		return "", fmt.Errorf("absolute path %q: %w", name, errUnsafeArchive)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("path traversal in %q: %w", name, errUnsafeArchive)
+	}
+	return clean, nil
+}
+
+// analyzable — нужно ли проверять файл: служебные и скрытые файлы (.git, __MACOSX)
+// пропускаются, как и файлы неизвестных форматов
+func analyzable(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return false
+		}
+	}
+	return codeLanguage(name) != "" || archiveDocumentExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+func (l *archiveLimiter) entry() error {
+	l.entries++
+	if l.entries > config.ArchiveMaxEntries {
+		return fmt.Errorf("more than %d entries: %w", config.ArchiveMaxEntries, errUnsafeArchive)
+	}
+	return nil
+}
+
+// read распаковывает запись, не выходя за оставшийся бюджет размера
+func (l *archiveLimiter) read(r io.Reader) ([]byte, error) {
+	remaining := config.ArchiveMaxSize - l.total
+	data, err := ioutil.ReadAll(io.LimitReader(r, remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	return data, l.count(int64(len(data)))
+}
+
+func (l *archiveLimiter) count(n int64) error {
+	l.total += n
+	if l.total > config.ArchiveMaxSize {
+		return fmt.Errorf("unpacked size exceeds %d bytes: %w", config.ArchiveMaxSize, errUnsafeArchive)
+	}
+	// Маленькие архивы сжимаются как угодно; степень сжатия проверяется после первого мегабайта
+	if l.total > 1<<20 && float64(l.total) > config.ArchiveMaxRatio*float64(l.packed) {
+		return fmt.Errorf("compression ratio exceeds %.0f: %w", config.ArchiveMaxRatio, errUnsafeArchive)
+	}
+	return nil
+}
+
+// unpackArchive распаковывает ZIP, TAR или TAR.GZ в память. Возвращает проверяемые файлы
+// и имена пропущенных; небезопасный архив отклоняется целиком.
+func unpackArchive(fileName string, data []byte) ([]archiveEntry, []string, error) {
+	l := &archiveLimiter{packed: int64(len(data))}
+	name := strings.ToLower(fileName)
+
+	if strings.HasSuffix(name, ".zip") {
+		return unpackZip(data, l)
+	}
+	var r io.Reader = bytes.NewReader(data)
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return unpackTar(r, l)
+}
+
+func unpackZip(data []byte, l *archiveLimiter) ([]archiveEntry, []string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	var files []archiveEntry
+	var skipped []string
+	for _, f := range zr.File {
+		if err := l.entry(); err != nil {
+			return nil, nil, err
+		}
+		name, err := archivePath(f.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() || !analyzable(name) {
+			skipped = append(skipped, name)
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		content, err := l.read(rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files = append(files, archiveEntry{Path: name, Data: content})
+	}
+	return files, skipped, nil
+}
+
+func unpackTar(r io.Reader, l *archiveLimiter) ([]archiveEntry, []string, error) {
+	tr := tar.NewReader(r)
+
+	var files []archiveEntry
+	var skipped []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if err := l.entry(); err != nil {
+			return nil, nil, err
+		}
+		name, err := archivePath(hdr.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch {
+		case hdr.Typeflag == tar.TypeDir:
+			continue
+		case hdr.Typeflag != tar.TypeReg || !analyzable(name):
+			// Пропущенная запись всё равно распаковывается потоком gzip, поэтому учитывается в размере
+			if err := l.count(hdr.Size); err != nil {
+				return nil, nil, err
+			}
+			skipped = append(skipped, name)
+			continue
+		}
+
+		content, err := l.read(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files = append(files, archiveEntry{Path: name, Data: content})
+	}
+	return files, skipped, nil
+}
+
+// analyzeArchive проверяет каждый файл проекта как отдельную сдачу задания (с именем
+// «архив/путь»), поэтому файлы сравниваются с подходящими файлами других сдач: код — по
+// отпечаткам кода, документы — текстовыми анализаторами. Итоговый отчёт содержит отчёты
+// по файлам, а его оценка и вердикт — худшие среди файлов.
+func analyzeArchive(req AnalysisRequest, files []archiveEntry, skipped []string) (Report, error) {
+	thresholds := resolveThresholds(req.Settings)
+	report := Report{
+		ID:           fmt.Sprintf("%d", documentID(req.Sender, req.WorkID, req.FileName)),
+		FileName:     req.FileName,
+		Sender:       req.Sender,
+		SenderName:   req.SenderName,
+		WorkID:       req.WorkID,
+		Late:         req.Late,
+		Format:       archiveFormat(req.FileName),
+		Mode:         "archive",
+		Verdict:      VerdictClean,
+		Thresholds:   &thresholds,
+		SkippedFiles: skipped,
+		Timestamp:    getCurrentTime(),
+	}
+
+	for _, f := range files {
+		fileReq := req
+		fileReq.FileName = req.FileName + "/" + f.Path
+
+		doc, err := extractTextCached(fileReq.FileName, f.Data)
+		if err != nil {
+			log.Printf("Error extracting text from %s: %v", fileReq.FileName, err)
+			report.Files = append(report.Files, Report{
+				FileName:  fileReq.FileName,
+				Sender:    req.Sender,
+				WorkID:    req.WorkID,
+				Timestamp: report.Timestamp,
+				Error:     "Unsupported or corrupt document",
+			})
+			continue
+		}
+
+		fileReport, err := analyzeSubmission(fileReq, doc)
+		if err != nil {
+			return report, fmt.Errorf("failed to analyze %s: %w", f.Path, err)
+		}
+		report.Files = append(report.Files, fileReport)
+		report.Similarity = max(report.Similarity, fileReport.Similarity)
+		report.Verdict = worseVerdict(report.Verdict, fileReport.Verdict)
+		if fileReport.EmbeddingMethod != "" && report.EmbeddingMethod != hashingVectorName {
+			report.EmbeddingMethod = fileReport.EmbeddingMethod
+		}
+		if o := fileReport.Obfuscation; o != nil {
+			if report.Obfuscation == nil {
+				report.Obfuscation = &Obfuscation{Detected: true}
+			}
+			report.Obfuscation.Homoglyphs += o.Homoglyphs
+			report.Obfuscation.Invisible += o.Invisible
+			report.Obfuscation.Compatibility += o.Compatibility
+		}
+	}
+
+	report.Plagiarized = report.Verdict == VerdictPlagiarized
+	flagObfuscation(&report)
+	return report, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFile — запись тестового архива; пустой Body у имени с "/" на конце — каталог
+type testFile struct {
+	Name string
+	Body string
+}
+
+func zipArchive(t *testing.T, files []testFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.Name)
+		if err != nil {
+			t.Fatalf("zip create %s: %v", f.Name, err)
+		}
+		w.Write([]byte(f.Body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files []testFile, symlink string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.Name, Mode: 0644, Size: int64(len(f.Body)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(f.Name, "/") {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar header %s: %v", f.Name, err)
+		}
+		tw.Write([]byte(f.Body))
+	}
+	if symlink != "" {
+		if err := tw.WriteHeader(&tar.Header{Name: symlink, Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}); err != nil {
+			t.Fatalf("tar symlink: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// withArchiveLimits подменяет ограничения распаковки на время теста
+func withArchiveLimits(t *testing.T, entries int, size int64, ratio float64) {
+	t.Helper()
+	saved := config
+	config.ArchiveMaxEntries, config.ArchiveMaxSize, config.ArchiveMaxRatio = entries, size, ratio
+	t.Cleanup(func() { config = saved })
+}
+
+func TestArchivePath(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		unsafe bool
+	}{
+		{"src/main.go", "src/main.go", false},
+		{"./src/../main.go", "main.go", false},
+		{`src\util\strings.go`, "src/util/strings.go", false},
+		{"src//a/./b.go", "src/a/b.go", false},
+		{"/etc/passwd", "", true},
+		{`\\server\share\a.go`, "", true},
+		{"C:/Windows/a.txt", "", true},
+		{`C:\Windows\a.txt`, "", true},
+		{"..", "", true},
+		{"../a.go", "", true},
+		{"src/../../a.go", "", true},
+		{`src\..\..\a.go`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := archivePath(tt.name)
+			if tt.unsafe {
+				if !errors.Is(err, errUnsafeArchive) {
+					t.Fatalf("archivePath(%q) = %q, %v; want errUnsafeArchive", tt.name, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("archivePath(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnpackArchive(t *testing.T) {
+	project := []testFile{
+		{"project/", ""},
+		{"project/main.go", "package main\n"},
+		{"project/README.md", "# Project\n"},
+		{"project/logo.png", "\x89PNG"},
+		{"project/.git/config", "[core]\n"},
+		{"__MACOSX/project/._main.go", "junk"},
+	}
+
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+		files    []string
+		skipped  []string
+	}{
+		{"zip", "project.zip", zipArchive(t, project),
+			[]string{"project/main.go", "project/README.md"},
+			[]string{"project/logo.png", "project/.git/config", "__MACOSX/project/._main.go"}},
+		{"tar.gz with symlink", "project.tar.gz", tarGzArchive(t, project, "project/passwd.txt"),
+			[]string{"project/main.go", "project/README.md"},
+			[]string{"project/logo.png", "project/.git/config", "__MACOSX/project/._main.go", "project/passwd.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, skipped, err := unpackArchive(tt.fileName, tt.data)
+			if err != nil {
+				t.Fatalf("unpackArchive: %v", err)
+			}
+			var paths []string
+			for _, f := range files {
+				paths = append(paths, f.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.files) {
+				t.Errorf("files %v, want %v", paths, tt.files)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped %v, want %v", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestUnpackArchiveLimits(t *testing.T) {
+	many := make([]testFile, 11)
+	for i := range many {
+		many[i] = testFile{Name: strings.Repeat("a", i+1) + ".txt", Body: "text"}
+	}
+	large := []testFile{{"a.txt", strings.Repeat("x", 600)}, {"b.txt", strings.Repeat("y", 600)}}
+	bomb := []testFile{{"bomb.txt", strings.Repeat("0", 4<<20)}}
+
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+		entries  int
+		size     int64
+		ratio    float64
+	}{
+		{"zip entries", "many.zip", zipArchive(t, many), 10, 1 << 20, 100},
+		{"tar entries", "many.tgz", tarGzArchive(t, many, ""), 10, 1 << 20, 100},
+		{"zip size", "large.zip", zipArchive(t, large), 100, 1000, 100},
+		{"tar size", "large.tar.gz", tarGzArchive(t, large, ""), 100, 1000, 100},
+		{"tar skipped size", "large.tar.gz", tarGzArchive(t, []testFile{{"a.bin", strings.Repeat("x", 1200)}}, ""), 100, 1000, 100},
+		{"zip ratio", "bomb.zip", zipArchive(t, bomb), 100, 100 << 20, 100},
+		{"tar ratio", "bomb.tar.gz", tarGzArchive(t, bomb, ""), 100, 100 << 20, 100},
+		{"zip traversal", "evil.zip", zipArchive(t, []testFile{{"../../etc/cron.d/x.txt", "x"}}), 100, 1 << 20, 100},
+		{"tar absolute", "evil.tar.gz", tarGzArchive(t, []testFile{{"/etc/passwd.txt", "x"}}, ""), 100, 1 << 20, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withArchiveLimits(t, tt.entries, tt.size, tt.ratio)
+			if _, _, err := unpackArchive(tt.fileName, tt.data); !errors.Is(err, errUnsafeArchive) {
+				t.Errorf("unpackArchive error = %v, want errUnsafeArchive", err)
+			}
+		})
+	}
+}
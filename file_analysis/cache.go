@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// normalizationVersion увеличивается при изменении извлечения текста, нормализации, токенизации
+// или стемминга: записи кэша прежних версий удаляются при старте
+const normalizationVersion = 1
+
+// ContentCache — постоянный кэш результатов, зависящих только от содержимого: извлечённого
+// текста (по SHA-256 файла), эмбеддингов фрагментов (по SHA-256 нормализованного текста и ID
+// модели) и отпечатков. Записи лежат в {dir}/v{версия}/{вид}/{ab}/{ключ}.json. nil — кэш выключен.
+type ContentCache struct {
+	dir string
+}
+
+var cache *ContentCache
+
+// openContentCache удаляет записи других версий нормализации и эмбеддинги других моделей
+func openContentCache(dir, modelID string) (*ContentCache, error) {
+	c := &ContentCache{dir: filepath.Join(dir, fmt.Sprintf("v%d", normalizationVersion))}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	stale, err := filepath.Glob(filepath.Join(dir, "v*"))
+	if err != nil {
+		return nil, err
+	}
+	models, err := filepath.Glob(filepath.Join(c.dir, "embeddings-*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range append(stale, models...) {
+		if path == c.dir || path == filepath.Join(c.dir, embeddingCacheKind(modelID)) {
+			continue
+		}
+		log.Printf("Removing stale cache %s", path)
+		if err := os.RemoveAll(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale cache: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// cacheKey — SHA-256 частей ключа, разделённых нулевым байтом
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// embeddingCacheKind — у каждой модели свой каталог эмбеддингов
+func embeddingCacheKind(modelID string) string {
+	return "embeddings-" + cacheKey(modelID)[:16]
+}
+
+func (c *ContentCache) path(kind, key string) string {
+	return filepath.Join(c.dir, kind, key[:2], key+".json")
+}
+
+// Get читает запись в v; испорченная запись считается промахом
+func (c *ContentCache) Get(kind, key string, v interface{}) bool {
+	if c == nil {
+		return false
+	}
+	data, err := ioutil.ReadFile(c.path(kind, key))
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Printf("Ignoring corrupt cache entry %s/%s: %v", kind, key, err)
+		return false
+	}
+	return true
+}
+
+// Put сохраняет запись; ошибки записи только логируются — кэш не должен ломать анализ
+func (c *ContentCache) Put(kind, key string, v interface{}) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to marshal cache entry %s/%s: %v", kind, key, err)
+		return
+	}
+
+	path := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("Failed to create cache directory: %v", err)
+		return
+	}
+	// Одинаковые файлы могут анализироваться одновременно, поэтому у каждой записи свой временный файл
+	tmp, err := ioutil.TempFile(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		log.Printf("Failed to write cache entry %s/%s: %v", kind, key, err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Failed to write cache entry %s/%s: %v", kind, key, err)
+	}
+}
+
+// extractTextCached — extractText с кэшем по содержимому файла. Формат определяется
+// и по расширению, поэтому оно входит в ключ; ошибки извлечения не кэшируются.
+func extractTextCached(fileName string, data []byte) (Document, error) {
+	sum := sha256.Sum256(data)
+	key := cacheKey(hex.EncodeToString(sum[:]), strings.ToLower(filepath.Ext(fileName)))
+
+	var doc Document
+	if cache.Get("text", key, &doc) {
+		return doc, nil
+	}
+	doc, err := extractText(fileName, data)
+	if err != nil {
+		return doc, err
+	}
+	cache.Put("text", key, doc)
+	return doc, nil
+}
+
+// lexicalFingerprints — отпечатки слов текста; ключ включает параметры winnowing, размер окна
+// определения языка и словарь лемм
+func lexicalFingerprints(text string, spans []languageSpan) []Fingerprint {
+	key := cacheKey(text, fmt.Sprintf("k%d w%d chunk%d", config.WinnowK, config.WinnowW, config.ChunkWords), lemmaDigest)
+
+	var fingerprints []Fingerprint
+	if cache.Get("lexical", key, &fingerprints) {
+		return fingerprints
+	}
+	fingerprints = winnow(stemTokens(tokenize(text), spans), config.WinnowK, config.WinnowW)
+	cache.Put("lexical", key, fingerprints)
+	return fingerprints
+}
+
+func codeFingerprintsCached(text, lang string) ([]Fingerprint, error) {
+	key := cacheKey(text, lang, fmt.Sprintf("k%d w%d", config.CodeWinnowK, config.CodeWinnowW))
+
+	var fingerprints []Fingerprint
+	if cache.Get("code", key, &fingerprints) {
+		return fingerprints, nil
+	}
+	fingerprints, err := codeFingerprints(text, lang)
+	if err != nil {
+		return nil, err
+	}
+	cache.Put("code", key, fingerprints)
+	return fingerprints, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Верхние половины однобайтовых кодовых страниц (байты 0x80-0xFF)
+var (
+	windows1251High = [128]rune{
+		0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021, 0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+		0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0xFFFD, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+		0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7, 0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+		0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7, 0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	}
+	windows1252High = [128]rune{
+		0x20AC, 0xFFFD, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021, 0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0xFFFD, 0x017D, 0xFFFD,
+		0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0xFFFD, 0x017E, 0x0178,
+	}
+	koi8rHigh = [128]rune{
+		0x2500, 0x2502, 0x250C, 0x2510, 0x2514, 0x2518, 0x251C, 0x2524, 0x252C, 0x2534, 0x253C, 0x2580, 0x2584, 0x2588, 0x258C, 0x2590,
+		0x2591, 0x2592, 0x2593, 0x2320, 0x25A0, 0x2219, 0x221A, 0x2248, 0x2264, 0x2265, 0x00A0, 0x2321, 0x00B0, 0x00B2, 0x00B7, 0x00F7,
+		0x2550, 0x2551, 0x2552, 0x0451, 0x2553, 0x2554, 0x2555, 0x2556, 0x2557, 0x2558, 0x2559, 0x255A, 0x255B, 0x255C, 0x255D, 0x255E,
+		0x255F, 0x2560, 0x2561, 0x0401, 0x2562, 0x2563, 0x2564, 0x2565, 0x2566, 0x2567, 0x2568, 0x2569, 0x256A, 0x256B, 0x256C, 0x00A9,
+	}
+	ibm866High = [128]rune{
+		0x30: 0x2591, 0x2592, 0x2593, 0x2502, 0x2524, 0x2561, 0x2562, 0x2556, 0x2555, 0x2563, 0x2551, 0x2557, 0x255D, 0x255C, 0x255B, 0x2510,
+		0x2514, 0x2534, 0x252C, 0x251C, 0x2500, 0x253C, 0x255E, 0x255F, 0x255A, 0x2554, 0x2569, 0x2566, 0x2560, 0x2550, 0x256C, 0x2567,
+		0x2568, 0x2564, 0x2565, 0x2559, 0x2558, 0x2552, 0x2553, 0x256B, 0x256A, 0x2518, 0x250C, 0x2588, 0x2584, 0x258C, 0x2590, 0x2580,
+		0x70: 0x0401, 0x0451, 0x0404, 0x0454, 0x0407, 0x0457, 0x040E, 0x045E, 0x00B0, 0x2219, 0x00B7, 0x221A, 0x2116, 0x00A4, 0x25A0, 0x00A0,
+	}
+)
+
+// Кодовые страницы однобайтовых кодировок по именам, которые попадают в отчёт
+var codepageNames = map[int]string{1251: "windows-1251", 20866: "koi8-r", 866: "ibm866", 1252: "windows-1252"}
+
+var codepageTables = map[int]*[128]rune{1251: &windows1251High, 20866: &koi8rHigh, 866: &ibm866High, 1252: &windows1252High}
+
+func init() {
+	// А-я занимают 0xC0-0xFF подряд; в Windows-1252 0xA0-0xFF совпадают с Latin-1
+	for i := 0x40; i < 0x80; i++ {
+		windows1251High[i] = rune(0x0410 + i - 0x40)
+	}
+	for i := 0x20; i < 0x80; i++ {
+		windows1252High[i] = rune(0x80 + i)
+	}
+	// В KOI8-R буквы идут в порядке латинского алфавита: сначала строчные, потом прописные
+	for i, r := range []rune("юабцдефгхийклмнопярстужвьызшэщчъ") {
+		koi8rHigh[0x40+i] = r
+		koi8rHigh[0x60+i] = unicode.ToUpper(r)
+	}
+	// В CP866 А-п занимают 0x80-0xAF, р-я — 0xE0-0xEF
+	for i := 0; i < 0x30; i++ {
+		ibm866High[i] = rune(0x0410 + i)
+	}
+	for i := 0; i < 0x10; i++ {
+		ibm866High[0x60+i] = rune(0x0440 + i)
+	}
+}
+
+// decodeCodepage переводит байты в однобайтовой кодовой странице Windows в строку UTF-8.
+// ASCII декодируется в любой кодовой странице; байты 0x80-0xFF в неизвестной заменяются
+// на U+FFFD, и тогда ok = false.
+func decodeCodepage(data []byte, codepage int) (text string, ok bool) {
+	if codepage == 65001 {
+		return string(data), true
+	}
+	high := codepageTables[codepage]
+
+	var b strings.Builder
+	b.Grow(len(data))
+	ok = true
+	for _, c := range data {
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case high == nil:
+			b.WriteRune(utf8.RuneError)
+			ok = false
+		default:
+			b.WriteRune(high[c-0x80])
+		}
+	}
+	return b.String(), ok
+}
+
+// Частоты строчных букв русского текста, в процентах
+var russianLetterFrequency = map[rune]float64{
+	'о': 10.97, 'е': 8.45, 'а': 8.01, 'и': 7.35, 'н': 6.70, 'т': 6.26, 'с': 5.47, 'р': 4.73, 'в': 4.54,
+	'л': 4.40, 'к': 3.49, 'м': 3.21, 'д': 2.98, 'п': 2.81, 'у': 2.62, 'я': 2.01, 'ы': 1.90, 'ь': 1.74,
+	'г': 1.70, 'з': 1.65, 'б': 1.59, 'ч': 1.44, 'й': 1.21, 'х': 0.97, 'ж': 0.94, 'ш': 0.73, 'ю': 0.64,
+	'ц': 0.48, 'щ': 0.36, 'э': 0.32, 'ф': 0.26, 'ъ': 0.04, 'ё': 0.04,
+}
+
+// Объём текста, по которому угадывается однобайтовая кодировка
+const encodingSampleSize = 64 << 10
+
+// decodeText переводит текстовый файл в UTF-8 и возвращает имя исходной кодировки.
+// Сначала проверяется BOM, затем корректность UTF-8; иначе из однобайтовых кириллических
+// кодировок выбирается та, в которой текст больше всего похож на русский.
+func decodeText(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], false), "utf-16le"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], true), "utf-16be"
+	case utf8.Valid(data):
+		return string(data), "utf-8"
+	}
+
+	sample := data[:min(len(data), encodingSampleSize)]
+	best, bestScore := 1252, 0.0
+	for _, codepage := range []int{1251, 20866, 866} {
+		text, _ := decodeCodepage(sample, codepage)
+		if score := russianScore(text); score > bestScore {
+			best, bestScore = codepage, score
+		}
+	}
+	text, _ := decodeCodepage(data, best)
+	return text, codepageNames[best]
+}
+
+// russianScore — насколько текст похож на русский: частые буквы прибавляют очки, а признаки
+// чужой кодировки отнимают — прописная буква посреди слова, кириллица вперемешку с латиницей
+// в одном слове (так выглядит латинский текст с диакритикой) и псевдографика
+func russianScore(text string) float64 {
+	isLatin := func(r rune) bool { return r < 0x80 && unicode.IsLetter(r) }
+
+	score := 0.0
+	prev := ' '
+	for _, r := range text {
+		cyrillic := unicode.Is(unicode.Cyrillic, r)
+		switch {
+		case cyrillic:
+			score += russianLetterFrequency[unicode.ToLower(r)]
+			if unicode.IsUpper(r) && unicode.IsLower(prev) || isLatin(prev) {
+				score -= 10
+			}
+		case isLatin(r) && unicode.Is(unicode.Cyrillic, prev):
+			score -= 10
+		case r >= 0x80 && !unicode.IsSpace(r) && !unicode.IsPunct(r):
+			score -= 5
+		}
+		prev = r
+	}
+	return score
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
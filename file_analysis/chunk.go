@@ -0,0 +1,81 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chunk — фрагмент документа; Start/End — смещения в символах (рунах)
+type Chunk struct {
+	Index    int
+	Start    int
+	End      int
+	Text     string
+	Language string
+}
+
+type wordSpan struct {
+	start, end         int // байтовые смещения
+	runeStart, runeEnd int
+}
+
+// splitChunks режет текст на перекрывающиеся окна по size слов с перекрытием overlap слов.
+// all-MiniLM обрезает длинный вход, поэтому каждое окно эмбедится отдельно.
+func splitChunks(text string, size, overlap int) []Chunk {
+	if size <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	words := splitWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var chunks []Chunk
+	step := size - overlap
+	for i := 0; i < len(words); i += step {
+		j := i + size
+		if j > len(words) {
+			j = len(words)
+		}
+		chunks = append(chunks, Chunk{
+			Index: len(chunks),
+			Start: words[i].runeStart,
+			End:   words[j-1].runeEnd,
+			Text:  text[words[i].start:words[j-1].end],
+		})
+		if j == len(words) {
+			break
+		}
+	}
+	return chunks
+}
+
+func splitWords(text string) []wordSpan {
+	var words []wordSpan
+	inWord := false
+	var cur wordSpan
+	runeIdx := 0
+
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if inWord {
+				cur.end, cur.runeEnd = i, runeIdx
+				words = append(words, cur)
+				inWord = false
+			}
+		} else if !inWord {
+			cur = wordSpan{start: i, runeStart: runeIdx}
+			inWord = true
+		}
+		runeIdx++
+	}
+	if inWord {
+		cur.end, cur.runeEnd = len(text), utf8.RuneCountInString(text)
+		words = append(words, cur)
+	}
+	return words
+}
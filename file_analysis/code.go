@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Максимальный разрыв (в строках) между совпавшими отпечатками внутри одного участка кода
+const codeRegionGap = 3
+
+// CodeRegion — совпавший участок кода; строки нумеруются с единицы, конец включается
+type CodeRegion struct {
+	StartLine      int     `json:"start_line"`
+	EndLine        int     `json:"end_line"`
+	OtherStartLine int     `json:"other_start_line"`
+	OtherEndLine   int     `json:"other_end_line"`
+	Score          float64 `json:"score"`
+	// Ячейки блокнотов, в которых начинаются участки (для обычных файлов не заполняются)
+	Cell      int `json:"cell,omitempty"`
+	OtherCell int `json:"other_cell,omitempty"`
+}
+
+type CodeMatch struct {
+	ID       string       `json:"id"`
+	FileName string       `json:"file_name"`
+	Sender   string       `json:"sender"`
+	Overlap  float64      `json:"overlap"`
+	Regions  []CodeRegion `json:"regions,omitempty"`
+}
+
+// codeLanguage определяет язык исходного кода сдачи. В режиме "text" код не распознаётся,
+// в режиме "code" файлы с неизвестным расширением разбираются как C-подобный язык. Если
+// анализаторы кода в задании выключены, файл проверяется как текст.
+func (s *WorkSettings) codeLanguage(fileName string) string {
+	mode := ""
+	if s != nil {
+		mode = s.Mode
+	}
+
+	switch lang := codeLanguage(fileName); {
+	case mode == "text":
+		return ""
+	case lang != "" && s.enabled("code"):
+		return lang
+	case lang == "go" && s.enabled("structure"):
+		return lang
+	case lang == "" && mode == "code" && s.enabled("code"):
+		return "c"
+	default:
+		return ""
+	}
+}
+
+// findCodeMatches сравнивает отпечатки кода с остальными сдачами задания (кроме своих).
+// Отпечатки кода хранят номера строк вместо смещений в символах.
+func findCodeMatches(rec fingerprintRecord, limit int) ([]CodeMatch, error) {
+	others, err := loadFingerprints(config.CodeFingerprintDir, rec.WorkID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []CodeMatch
+	for _, other := range others {
+		if other.SubmissionID == rec.SubmissionID || other.Sender == rec.Sender {
+			continue
+		}
+
+		overlap, passages := compareFingerprints(rec.Fingerprints, other.Fingerprints, codeRegionGap)
+		if overlap == 0 {
+			continue
+		}
+
+		regions := make([]CodeRegion, len(passages))
+		for i, p := range passages {
+			regions[i] = CodeRegion{
+				StartLine:      p.Start,
+				EndLine:        p.End,
+				OtherStartLine: p.OtherStart,
+				OtherEndLine:   p.OtherEnd,
+				Score:          p.Score,
+			}
+		}
+		matches = append(matches, CodeMatch{
+			ID:       other.SubmissionID,
+			FileName: other.FileName,
+			Sender:   other.Sender,
+			Overlap:  overlap,
+			Regions:  regions,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Overlap > matches[j].Overlap })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func codeFingerprints(text, lang string) ([]Fingerprint, error) {
+	if _, ok := codeKeywords[lang]; !ok {
+		return nil, fmt.Errorf("unsupported language %q", lang)
+	}
+	return winnow(tokenizeCode(text, lang), config.CodeWinnowK, config.CodeWinnowW), nil
+}
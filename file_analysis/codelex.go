@@ -0,0 +1,231 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Нормализованные классы токенов: переименование переменных и замена литералов
+// не меняют поток токенов
+const (
+	codeIdent  = "V"
+	codeNumber = "N"
+	codeString = "S"
+)
+
+var codeExtensions = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".java": "java",
+	".c":    "c",
+	".h":    "c",
+	".cpp":  "cpp",
+	".cc":   "cpp",
+	".cxx":  "cpp",
+	".hpp":  "cpp",
+	".hh":   "cpp",
+}
+
+func codeLanguage(fileName string) string {
+	return codeExtensions[strings.ToLower(filepath.Ext(fileName))]
+}
+
+func keywordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+const cKeywords = `auto break case char const continue default do double else enum extern float for goto if
+	inline int long register restrict return short signed sizeof static struct switch typedef union unsigned
+	void volatile while bool true false NULL`
+
+var codeKeywords = map[string]map[string]bool{
+	"go": keywordSet(`break case chan const continue default defer else fallthrough for func go goto if
+		import interface map package range return select struct switch type var nil true false iota
+		int int8 int16 int32 int64 uint uint8 uint16 uint32 uint64 uintptr float32 float64 string bool
+		byte rune error any make new len cap append copy delete panic recover`),
+	"python": keywordSet(`False None True and as assert async await break class continue def del elif else
+		except finally for from global if import in is lambda nonlocal not or pass raise return try while
+		with yield self`),
+	"java": keywordSet(`abstract assert boolean break byte case catch char class const continue default do
+		double else enum extends final finally float for goto if implements import instanceof int interface
+		long native new package private protected public return short static strictfp super switch
+		synchronized this throw throws transient try void volatile while true false null var record`),
+	"c": keywordSet(cKeywords),
+	"cpp": keywordSet(cKeywords + ` alignas alignof and asm catch class constexpr const_cast decltype delete
+		dynamic_cast explicit export friend mutable namespace new noexcept nullptr operator or private
+		protected public reinterpret_cast static_assert static_cast template this throw try typeid typename
+		using virtual std string vector map cout cin endl`),
+}
+
+// Многосимвольные операторы, от длинных к коротким
+var codeOperators = []string{
+	">>>=", "<<=", ">>=", "...", "**=", "//=", "&^=",
+	"->", "++", "--", "&&", "||", "==", "!=", "<=", ">=", "+=", "-=", "*=", "/=", "%=",
+	"&=", "|=", "^=", "<<", ">>", "::", ":=", "**", "//", "&^", "<-",
+}
+
+// tokenizeCode разбирает исходный код в поток нормализованных токенов с номерами строк.
+// Комментарии, директивы препроцессора и пробелы отбрасываются.
+func tokenizeCode(src, lang string) []Token {
+	keywords := codeKeywords[lang]
+	python := lang == "python"
+	cFamily := lang == "c" || lang == "cpp"
+
+	rs := []rune(src)
+	var tokens []Token
+	line := 1
+	lineStart := true
+
+	emit := func(text string, startLine int) {
+		tokens = append(tokens, Token{Text: text, Start: startLine, End: line})
+	}
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		next := rune(0)
+		if i+1 < len(rs) {
+			next = rs[i+1]
+		}
+
+		switch {
+		case r == '\n':
+			line++
+			lineStart = true
+			i++
+			continue
+
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case python && r == '#', !python && r == '/' && next == '/':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			continue
+
+		case !python && r == '/' && next == '*':
+			i += 2
+			for i < len(rs) && !(rs[i] == '*' && i+1 < len(rs) && rs[i+1] == '/') {
+				if rs[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i += 2
+			continue
+
+		case cFamily && r == '#' && lineStart:
+			for i < len(rs) && rs[i] != '\n' {
+				if rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == '\n' {
+					line++
+					i++
+				}
+				i++
+			}
+			continue
+		}
+
+		lineStart = false
+		startLine := line
+
+		switch {
+		case r == '"' || r == '\'' || (r == '`' && lang == "go"):
+			i = skipString(rs, i, python, &line)
+			emit(codeString, startLine)
+
+		case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(next)):
+			for i < len(rs) && (isIdentRune(rs[i]) || rs[i] == '.' ||
+				((rs[i] == '+' || rs[i] == '-') && strings.ContainsRune("eEpP", rs[i-1]))) {
+				i++
+			}
+			emit(codeNumber, startLine)
+
+		case isIdentStart(r):
+			j := i
+			for j < len(rs) && isIdentRune(rs[j]) {
+				j++
+			}
+			word := string(rs[i:j])
+			// Префиксы строк Python: r"...", f'...', b"""..."""
+			if python && j < len(rs) && (rs[j] == '"' || rs[j] == '\'') && len(word) <= 2 &&
+				strings.Trim(strings.ToLower(word), "rbfu") == "" {
+				i = skipString(rs, j, python, &line)
+				emit(codeString, startLine)
+				continue
+			}
+			i = j
+			if keywords[word] {
+				emit(word, startLine)
+			} else {
+				emit(codeIdent, startLine)
+			}
+
+		default:
+			op := string(r)
+			for _, candidate := range codeOperators {
+				if strings.HasPrefix(string(rs[i:min(i+len(candidate), len(rs))]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			i += len([]rune(op))
+			emit(op, startLine)
+		}
+	}
+	return tokens
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || r == '$'
+}
+
+func isIdentRune(r rune) bool {
+	return isIdentStart(r) || unicode.IsDigit(r)
+}
+
+// skipString пропускает строковый литерал, начинающийся с rs[i], и возвращает индекс после него
+func skipString(rs []rune, i int, python bool, line *int) int {
+	quote := rs[i]
+
+	if python && i+2 < len(rs) && rs[i+1] == quote && rs[i+2] == quote {
+		i += 3
+		for i < len(rs) {
+			if rs[i] == '\\' {
+				i += 2
+				continue
+			}
+			if rs[i] == '\n' {
+				*line++
+			}
+			if rs[i] == quote && i+2 < len(rs) && rs[i+1] == quote && rs[i+2] == quote {
+				return i + 3
+			}
+			i++
+		}
+		return len(rs)
+	}
+
+	i++
+	for i < len(rs) {
+		switch {
+		case rs[i] == '\\' && quote != '`':
+			i += 2
+			continue
+		case rs[i] == quote:
+			return i + 1
+		case rs[i] == '\n':
+			if quote != '`' {
+				return i
+			}
+			*line++
+		}
+		i++
+	}
+	return len(rs)
+}
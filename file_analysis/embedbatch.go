@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// embeddingItem — один текст в очереди на эмбеддинг и канал для ответа
+type embeddingItem struct {
+	text   string
+	result chan embeddingResult
+}
+
+type embeddingResult struct {
+	vector []float32
+	err    error
+}
+
+// EmbeddingBatcher собирает тексты из параллельных анализов (и фрагменты одного документа)
+// в пакетные запросы к провайдеру. Каждый воркер ждёт первый текст, добирает пакет до
+// maxBatch или пока не пройдёт maxWait и отправляет его; пока воркеры заняты, очередь копится,
+// поэтому при всплеске сдач пакеты сами становятся крупнее, а в простое текст уходит почти сразу.
+type EmbeddingBatcher struct {
+	provider EmbeddingProvider
+	queue    chan embeddingItem
+	maxBatch int
+	maxWait  time.Duration
+}
+
+func newEmbeddingBatcher(provider EmbeddingProvider, maxBatch int, maxWait time.Duration, workers int) (*EmbeddingBatcher, error) {
+	// Без воркеров очередь никто не разбирает и Embed ждал бы вечно
+	if workers <= 0 {
+		return nil, fmt.Errorf("embedding workers must be positive, got %d", workers)
+	}
+	if maxBatch <= 0 {
+		return nil, fmt.Errorf("embedding batch size must be positive, got %d", maxBatch)
+	}
+	if maxWait < 0 {
+		return nil, fmt.Errorf("embedding batch wait must not be negative, got %v", maxWait)
+	}
+
+	b := &EmbeddingBatcher{
+		provider: provider,
+		queue:    make(chan embeddingItem, maxBatch*workers),
+		maxBatch: maxBatch,
+		maxWait:  maxWait,
+	}
+	for i := 0; i < workers; i++ {
+		go b.worker()
+	}
+	return b, nil
+}
+
+// Embed ставит тексты в очередь и ждёт векторы для всех; порядок ответа совпадает с texts
+func (b *EmbeddingBatcher) Embed(texts []string) ([][]float32, error) {
+	items := make([]embeddingItem, len(texts))
+	for i, text := range texts {
+		items[i] = embeddingItem{text: text, result: make(chan embeddingResult, 1)}
+		b.queue <- items[i]
+	}
+
+	vectors := make([][]float32, len(texts))
+	var firstErr error
+	for i, item := range items {
+		r := <-item.result
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+		vectors[i] = r.vector
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return vectors, nil
+}
+
+func (b *EmbeddingBatcher) worker() {
+	for {
+		batch := []embeddingItem{<-b.queue}
+		timer := time.NewTimer(b.maxWait)
+	collect:
+		for len(batch) < b.maxBatch {
+			select {
+			case item := <-b.queue:
+				batch = append(batch, item)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+		b.send(batch)
+	}
+}
+
+func (b *EmbeddingBatcher) send(batch []embeddingItem) {
+	texts := make([]string, len(batch))
+	for i, item := range batch {
+		texts[i] = item.text
+	}
+
+	start := time.Now()
+	vectors, err := b.embed(texts)
+	if err == nil {
+		log.Printf("Embedded batch of %d in %v", len(batch), time.Since(start).Round(time.Millisecond))
+		for i, item := range batch {
+			item.result <- b.result(vectors[i])
+		}
+		return
+	}
+	// В пакете тексты разных анализов: если сервис отклонил пакет, виноват может быть один текст,
+	// поэтому тексты повторяются по одному. Сетевые ошибки и таймауты от текстов не зависят, а
+	// повторы лишь задержали бы переход на запасной вектор, поэтому пакет сразу получает ошибку.
+	if len(batch) == 1 || !errors.Is(err, errEmbeddingRejected) {
+		log.Printf("Embedding batch of %d failed: %v", len(batch), err)
+		for _, item := range batch {
+			item.result <- embeddingResult{err: err}
+		}
+		return
+	}
+	log.Printf("Embedding batch of %d failed, retrying texts one by one: %v", len(batch), err)
+	for _, item := range batch {
+		vectors, err := b.embed([]string{item.text})
+		if err != nil {
+			item.result <- embeddingResult{err: err}
+			continue
+		}
+		item.result <- b.result(vectors[0])
+	}
+}
+
+// embed запрашивает векторы у провайдера и проверяет, что их столько же, сколько текстов
+func (b *EmbeddingBatcher) embed(texts []string) ([][]float32, error) {
+	vectors, err := b.provider.Embed(texts)
+	if err == nil && len(vectors) != len(texts) {
+		err = fmt.Errorf("%w: %d vectors for %d texts", errEmbeddingRejected, len(vectors), len(texts))
+	}
+	return vectors, err
+}
+
+func (b *EmbeddingBatcher) result(vector []float32) embeddingResult {
+	if len(vector) != b.provider.Dimension() {
+		return embeddingResult{err: fmt.Errorf("unexpected embedding dimension: got %d, expected %d",
+			len(vector), b.provider.Dimension())}
+	}
+	return embeddingResult{vector: vector}
+}
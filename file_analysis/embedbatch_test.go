@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEmbedder отклоняет запросы с текстом "bad" или отвечает ошибкой fail на любой запрос
+type fakeEmbedder struct {
+	mu    sync.Mutex
+	calls int
+	fail  error
+}
+
+func (f *fakeEmbedder) Model() string  { return "fake" }
+func (f *fakeEmbedder) Dimension() int { return 2 }
+
+func (f *fakeEmbedder) Embed(texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	if f.fail != nil {
+		return nil, f.fail
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if text == "bad" {
+			return nil, fmt.Errorf("%w [422]: text too long", errEmbeddingRejected)
+		}
+		vectors[i] = []float32{1, 0}
+	}
+	return vectors, nil
+}
+
+func TestNewEmbeddingBatcherValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		batch   int
+		wait    time.Duration
+		workers int
+	}{
+		{"no workers", 32, time.Millisecond, 0},
+		{"zero batch", 0, time.Millisecond, 2},
+		{"negative batch", -1, time.Millisecond, 2},
+		{"negative wait", 32, -time.Millisecond, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newEmbeddingBatcher(&fakeEmbedder{}, tt.batch, tt.wait, tt.workers); err == nil {
+				t.Error("newEmbeddingBatcher accepted invalid settings")
+			}
+		})
+	}
+}
+
+// embedConcurrently отправляет группы текстов одновременно, чтобы они попали в один пакет
+func embedConcurrently(b *EmbeddingBatcher, groups ...[]string) []error {
+	errs := make([]error, len(groups))
+	var wg sync.WaitGroup
+	for i, texts := range groups {
+		wg.Add(1)
+		go func(i int, texts []string) {
+			defer wg.Done()
+			_, errs[i] = b.Embed(texts)
+		}(i, texts)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestEmbeddingBatcherRetriesRejectedBatch(t *testing.T) {
+	provider := &fakeEmbedder{}
+	b, err := newEmbeddingBatcher(provider, 32, 50*time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := embedConcurrently(b, []string{"a", "b"}, []string{"bad"})
+	if errs[0] != nil {
+		t.Errorf("analysis without the bad text failed: %v", errs[0])
+	}
+	if !errors.Is(errs[1], errEmbeddingRejected) {
+		t.Errorf("analysis with the bad text: %v, want errEmbeddingRejected", errs[1])
+	}
+	if provider.calls != 4 {
+		t.Errorf("%d provider calls, want 1 batch and 3 single retries", provider.calls)
+	}
+}
+
+func TestEmbeddingBatcherFailsBatchOnTransportError(t *testing.T) {
+	down := errors.New("connection refused")
+	provider := &fakeEmbedder{fail: down}
+	b, err := newEmbeddingBatcher(provider, 32, 50*time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, err := range embedConcurrently(b, []string{"a", "b"}, []string{"c"}) {
+		if !errors.Is(err, down) {
+			t.Errorf("analysis %d: %v, want the transport error", i, err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("%d provider calls, want the batch to fail without retries", provider.calls)
+	}
+}
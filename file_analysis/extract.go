@@ -0,0 +1,416 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ограничение на распакованный размер одной XML-части документа
+const maxDocumentPart = 64 << 20
+
+var errUnsupportedFormat = errors.New("unsupported document format")
+
+// Document — текст, извлечённый из загруженного файла; абзацы разделены пустой строкой.
+// Blocks связывают участки текста с местом в исходном документе, если формат это позволяет,
+// Sections — заголовки разделов по порядку. У блокнотов Jupyter код ячеек идёт отдельно
+// от текста: CodeBlocks связывают строки Code (Start включительно, End нет) с ячейками.
+type Document struct {
+	Format       string
+	Encoding     string // исходная кодировка текстовых форматов
+	Text         string
+	Blocks       []Block
+	Sections     []string
+	Code         string
+	CodeLanguage string
+	CodeBlocks   []Block
+}
+
+// extractText определяет формат по сигнатуре и расширению файла и извлекает из него текст
+func extractText(fileName string, data []byte) (Document, error) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return extractPDF(data)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return extractZipDocument(data)
+	case bytes.HasPrefix(data, []byte(`{\rtf`)):
+		text, err := extractRTF(data)
+		return Document{Format: "rtf", Text: text}, err
+	case ext == ".pdf" || ext == ".docx" || ext == ".odt" || ext == ".pptx" || ext == ".rtf":
+		return Document{}, fmt.Errorf("%s file is corrupt: %w", ext, errUnsupportedFormat)
+	case ext == ".ipynb":
+		return extractNotebook(data)
+	}
+
+	// Остальное — текстовые файлы в произвольной кодировке
+	text, encoding := decodeText(data)
+	var doc Document
+	switch ext {
+	case ".tex", ".latex":
+		doc = extractLatex(text)
+	case ".md", ".markdown":
+		doc = extractMarkdown(text)
+	default:
+		doc = Document{Format: "text", Text: text}
+	}
+	doc.Encoding = encoding
+	return doc, nil
+}
+
+func extractZipDocument(data []byte) (Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	switch {
+	case files["word/document.xml"] != nil:
+		text, err := xmlParagraphs(files["word/document.xml"], ooxmlWord)
+		return Document{Format: "docx", Text: text}, err
+
+	case files["content.xml"] != nil:
+		text, err := xmlParagraphs(files["content.xml"], odfText)
+		return Document{Format: "odt", Text: text}, err
+
+	case files["ppt/presentation.xml"] != nil:
+		var slides []string
+		for _, f := range pptxSlides(zr.File) {
+			text, err := xmlParagraphs(f, ooxmlSlide)
+			if err != nil {
+				return Document{}, err
+			}
+			if text != "" {
+				slides = append(slides, text)
+			}
+		}
+		return Document{Format: "pptx", Text: strings.Join(slides, "\n\n")}, nil
+	}
+	return Document{}, errUnsupportedFormat
+}
+
+var slideNamePattern = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// pptxSlides возвращает слайды в порядке номеров (slide10 после slide9)
+func pptxSlides(files []*zip.File) []*zip.File {
+	type slide struct {
+		n int
+		f *zip.File
+	}
+	var slides []slide
+	for _, f := range files {
+		if m := slideNamePattern.FindStringSubmatch(f.Name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			slides = append(slides, slide{n, f})
+		}
+	}
+	sort.Slice(slides, func(i, j int) bool { return slides[i].n < slides[j].n })
+
+	result := make([]*zip.File, len(slides))
+	for i, s := range slides {
+		result[i] = s.f
+	}
+	return result
+}
+
+// xmlTextSpec описывает, где в XML-разметке формата лежит текст.
+// Элементы сравниваются по локальному имени без пространства имён.
+type xmlTextSpec struct {
+	paragraphs map[string]bool // элементы-абзацы
+	text       string          // элемент с текстом; пусто — весь текст внутри абзаца
+	tab        string
+	breaks     map[string]bool // разрывы строк внутри абзаца
+	space      string          // ODF text:s — c пробелов подряд
+	skip       map[string]bool // поддеревья, текст которых не нужен
+}
+
+var (
+	ooxmlWord = xmlTextSpec{
+		paragraphs: map[string]bool{"p": true},
+		text:       "t",
+		tab:        "tab",
+		breaks:     map[string]bool{"br": true, "cr": true},
+		skip:       map[string]bool{"pPr": true, "instrText": true, "delText": true},
+	}
+	ooxmlSlide = xmlTextSpec{
+		paragraphs: map[string]bool{"p": true},
+		text:       "t",
+		breaks:     map[string]bool{"br": true},
+	}
+	odfText = xmlTextSpec{
+		paragraphs: map[string]bool{"p": true, "h": true},
+		tab:        "tab",
+		breaks:     map[string]bool{"line-break": true},
+		space:      "s",
+		skip:       map[string]bool{"tracked-changes": true, "annotation": true},
+	}
+)
+
+func xmlParagraphs(f *zip.File, spec xmlTextSpec) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	dec := xml.NewDecoder(io.LimitReader(rc, maxDocumentPart))
+	var paragraphs []string
+	var cur strings.Builder
+	depth, skipDepth, textDepth := 0, 0, 0
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", f.Name, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			switch {
+			case skipDepth > 0 || spec.skip[name]:
+				skipDepth++
+			case spec.paragraphs[name]:
+				depth++
+			case depth == 0:
+			case name == spec.text:
+				textDepth++
+			case name == spec.tab:
+				cur.WriteByte('\t')
+			case spec.breaks[name]:
+				cur.WriteByte('\n')
+			case name == spec.space:
+				n := 1
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "c" {
+						if c, err := strconv.Atoi(attr.Value); err == nil && c > 0 && c < 1024 {
+							n = c
+						}
+					}
+				}
+				cur.WriteString(strings.Repeat(" ", n))
+			}
+
+		case xml.EndElement:
+			name := t.Name.Local
+			switch {
+			case skipDepth > 0:
+				skipDepth--
+			case spec.paragraphs[name]:
+				depth--
+				// Вложенные абзацы (сноски внутри абзаца) не разрывают внешний
+				if depth == 0 {
+					if p := strings.TrimSpace(cur.String()); p != "" {
+						paragraphs = append(paragraphs, p)
+					}
+					cur.Reset()
+				}
+			case name == spec.text && textDepth > 0:
+				textDepth--
+			}
+
+		case xml.CharData:
+			if skipDepth == 0 && depth > 0 && (spec.text == "" || textDepth > 0) {
+				cur.Write(t)
+			}
+		}
+	}
+	return strings.Join(paragraphs, "\n\n"), nil
+}
+
+// Группы RTF, содержимое которых не является текстом документа
+var rtfDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true,
+	"header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true,
+	"themedata": true, "colorschememapping": true, "latentstyles": true, "datastore": true,
+	"xmlnstbl": true, "listtable": true, "listoverridetable": true, "rsidtbl": true,
+	"generator": true, "object": true, "fldinst": true, "filetbl": true, "revtbl": true,
+}
+
+var rtfSymbols = map[string]string{
+	"par": "\n\n", "sect": "\n\n", "page": "\n\n", "line": "\n", "row": "\n", "cell": "\t", "tab": "\t",
+	"emdash": "—", "endash": "–", "bullet": "•", "lquote": "‘", "rquote": "’",
+	"ldblquote": "“", "rdblquote": "”", "emspace": " ", "enspace": " ",
+}
+
+type rtfState struct {
+	skip        bool
+	unicodeSkip int
+}
+
+// extractRTF — упрощённый разбор RTF: управляющие слова, группы-назначения,
+// \'hh в кодовой странице документа (\ansicpg) и \uN. Символы вне ASCII в кодовой
+// странице, которую мы не знаем, заменяются на U+FFFD, а не читаются как Windows-1252.
+func extractRTF(data []byte) (string, error) {
+	var out strings.Builder
+	var pending []byte // байты текста и \'hh, декодируются пачкой в кодировке документа
+	codepage := 1252
+	state := rtfState{unicodeSkip: 1}
+	var stack []rtfState
+	fallback := 0 // сколько символов-заменителей после \uN ещё пропустить
+	replaced := false
+
+	flush := func() {
+		if len(pending) > 0 {
+			text, ok := decodeCodepage(pending, codepage)
+			if !ok && !replaced {
+				log.Printf("RTF code page %d is not supported, non-ASCII characters replaced with U+FFFD", codepage)
+				replaced = true
+			}
+			out.WriteString(text)
+			pending = pending[:0]
+		}
+	}
+	emit := func(s string) {
+		if state.skip {
+			return
+		}
+		if fallback > 0 {
+			fallback--
+			return
+		}
+		flush()
+		out.WriteString(s)
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '{':
+			stack = append(stack, state)
+			i++
+			// {\*\dest ...} — необязательное назначение, которое можно пропустить целиком
+			if bytes.HasPrefix(data[i:], []byte(`\*`)) {
+				state.skip = true
+			}
+
+		case c == '}':
+			if len(stack) == 0 {
+				return "", fmt.Errorf("unbalanced RTF group")
+			}
+			state = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			i++
+
+		case c == '\r' || c == '\n':
+			i++
+
+		case c == '\\' && i+1 < len(data):
+			next := data[i+1]
+			switch {
+			case next == '\'' && i+3 < len(data):
+				b, err := strconv.ParseUint(string(data[i+2:i+4]), 16, 8)
+				i += 4
+				if err != nil || state.skip {
+					continue
+				}
+				if fallback > 0 {
+					fallback--
+					continue
+				}
+				pending = append(pending, byte(b))
+
+			case isASCIILetter(next):
+				j := i + 1
+				for j < len(data) && isASCIILetter(data[j]) {
+					j++
+				}
+				word := string(data[i+1 : j])
+				k := j
+				if k < len(data) && (data[k] == '-' || (data[k] >= '0' && data[k] <= '9')) {
+					k++
+					for k < len(data) && data[k] >= '0' && data[k] <= '9' {
+						k++
+					}
+				}
+				param, hasParam := 0, k > j
+				if hasParam {
+					param, _ = strconv.Atoi(string(data[j:k]))
+				}
+				if k < len(data) && data[k] == ' ' {
+					k++
+				}
+				i = k
+
+				switch {
+				case rtfDestinations[word]:
+					state.skip = true
+				case word == "ansicpg" && hasParam:
+					codepage = param
+				case word == "uc" && hasParam:
+					state.unicodeSkip = param
+				case word == "u" && hasParam:
+					if param < 0 {
+						param += 65536
+					}
+					emit(string(rune(param)))
+					fallback = state.unicodeSkip
+				case rtfSymbols[word] != "":
+					emit(rtfSymbols[word])
+				}
+
+			default:
+				i += 2
+				switch next {
+				case '~':
+					emit(" ")
+				case '_':
+					emit("-")
+				case '{', '}', '\\':
+					emit(string(next))
+				case '\r', '\n':
+					emit("\n\n")
+				}
+			}
+
+		default:
+			j := i
+			for j < len(data) && data[j] != '{' && data[j] != '}' && data[j] != '\\' && data[j] != '\r' && data[j] != '\n' {
+				j++
+			}
+			for _, b := range data[i:j] {
+				if state.skip {
+					break
+				}
+				if fallback > 0 {
+					fallback--
+					continue
+				}
+				pending = append(pending, b)
+			}
+			i = j
+		}
+	}
+	flush()
+
+	var paragraphs []string
+	for _, p := range strings.Split(out.String(), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n"), nil
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
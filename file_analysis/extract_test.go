@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestExtractRTFCodepages(t *testing.T) {
+	tests := []struct {
+		name string
+		rtf  string
+		want string
+	}{
+		{"windows-1251", `{\rtf1\ansi\ansicpg1251 {\fonttbl{\f0 Arial;}}\'cf\'f0\'e8\'e2\'e5\'f2, \'ec\'e8\'f0}`, "Привет, мир"},
+		{"default windows-1252", `{\rtf1\ansi caf\'e9}`, "café"},
+		{"utf-8", `{\rtf1\ansi\ansicpg65001 \'d0\'bc\'d0\'b8\'d1\'80}`, "мир"},
+		{"unicode escapes", `{\rtf1\ansi\ansicpg1250\uc1 \u1084?\u1080?\u1088?}`, "мир"},
+		// Неизвестная кодовая страница не отклоняет документ: ASCII сохраняется, остальное — U+FFFD
+		{"unknown code page", `{\rtf1\ansi\ansicpg1250 Dobr\'fd den}`, "Dobr� den"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractRTF([]byte(tt.rtf))
+			if err != nil {
+				t.Fatalf("extractRTF: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("extractRTF = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCodepage(t *testing.T) {
+	if text, ok := decodeCodepage([]byte("abc\xe0"), 1251); !ok || text != "abcа" {
+		t.Errorf("decodeCodepage(1251) = %q, %v", text, ok)
+	}
+	if text, ok := decodeCodepage([]byte("abc\xe0"), 1250); ok || text != "abc�" {
+		t.Errorf("decodeCodepage(1250) = %q, %v; want replacement and ok = false", text, ok)
+	}
+	if text, ok := decodeCodepage([]byte("abc"), 1250); !ok || text != "abc" {
+		t.Errorf("decodeCodepage(1250) of ASCII = %q, %v", text, ok)
+	}
+}
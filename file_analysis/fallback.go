@@ -0,0 +1,56 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Имена векторов точки хранилища: эмбеддинг модели и запасной хеширующий вектор
+const (
+	modelVectorName   = "model"
+	hashingVectorName = "hashing"
+)
+
+// hashingVector — запасной векторизатор на Go на случай, когда сервис эмбеддингов недоступен:
+// основы слов (без стоп-слов) и пары соседних основ хешируются в dim измерений со знаком
+// (feature hashing), веса — сублинейная частота 1+ln(tf), вектор нормируется. Смысла он не
+// понимает, но пересказ близко к тексту и перестановку предложений находит.
+func hashingVector(text, lang string, dim int) []float32 {
+	counts := make(map[string]int)
+	prev := ""
+	for _, token := range tokenize(text) {
+		if l := languageFor(token.Text, lang); l != nil && l.StopWords[token.Text] {
+			continue
+		}
+		stem := normalizeWord(token.Text, lang)
+		counts[stem]++
+		if prev != "" {
+			counts[prev+" "+stem]++
+		}
+		prev = stem
+	}
+
+	vector := make([]float32, dim)
+	for feature, tf := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		weight := float32(1 + math.Log(float64(tf)))
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(dim)] += weight
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
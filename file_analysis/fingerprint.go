@@ -0,0 +1,100 @@
+package main
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// Fingerprint — хеш k-граммы нормализованных токенов и её положение в тексте (в символах)
+type Fingerprint struct {
+	Hash  uint64 `json:"h"`
+	Start int    `json:"s"`
+	End   int    `json:"e"`
+}
+
+// Token — нормализованное слово с исходными смещениями в символах
+type Token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// tokenize приводит текст к нижнему регистру и оставляет только буквенно-цифровые слова
+func tokenize(text string) []Token {
+	var tokens []Token
+	var b strings.Builder
+	start := -1
+	pos := 0
+
+	flush := func() {
+		if start >= 0 {
+			tokens = append(tokens, Token{Text: b.String(), Start: start, End: pos})
+			b.Reset()
+			start = -1
+		}
+	}
+
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = pos
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			flush()
+		}
+		pos++
+	}
+	flush()
+	return tokens
+}
+
+// winnow — алгоритм winnowing (Schleimer, Wilkerson, Aiken; MOSS): из каждого окна
+// в w подряд идущих хешей k-грамм выбирается минимальный (при равенстве — самый правый).
+// Гарантируется, что любое совпадение длиной не меньше w+k-1 токенов даст общий отпечаток.
+func winnow(tokens []Token, k, w int) []Fingerprint {
+	if k <= 0 || w <= 0 || len(tokens) < k {
+		return nil
+	}
+
+	grams := make([]Fingerprint, len(tokens)-k+1)
+	for i := range grams {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+k] {
+			h.Write([]byte(t.Text))
+			h.Write([]byte{0})
+		}
+		grams[i] = Fingerprint{Hash: h.Sum64(), Start: tokens[i].Start, End: tokens[i+k-1].End}
+	}
+
+	if len(grams) <= w {
+		return []Fingerprint{minGram(grams)}
+	}
+
+	var result []Fingerprint
+	last := -1
+	for i := 0; i+w <= len(grams); i++ {
+		minIdx := i
+		for j := i; j < i+w; j++ {
+			if grams[j].Hash <= grams[minIdx].Hash {
+				minIdx = j
+			}
+		}
+		if minIdx != last {
+			result = append(result, grams[minIdx])
+			last = minIdx
+		}
+	}
+	return result
+}
+
+func minGram(grams []Fingerprint) Fingerprint {
+	m := grams[0]
+	for _, g := range grams[1:] {
+		if g.Hash <= m.Hash {
+			m = g
+		}
+	}
+	return m
+}
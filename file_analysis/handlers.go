@@ -25,89 +25,149 @@ func handleAnalyze(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		FileName string `json:"file_name"`
-		Sender   string `json:"sender"`
-		WorkID   string `json:"work_id"`
-	}
-
+	var req AnalysisRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("Error decoding request: %v", err)
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	content, err := ioutil.ReadFile(filepath.Join("/files", req.FileName))
+	if req.StoredName == "" || req.FileName == "" {
+		http.Error(w, "file_name and stored_name are required", http.StatusBadRequest)
+		return
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join("/files", filepath.Base(req.StoredName)))
 	if err != nil {
-		log.Printf("Error reading file %s: %v", req.FileName, err)
+		log.Printf("Error reading file %s (%s): %v", req.StoredName, req.FileName, err)
 		http.Error(w, "File not found", http.StatusNotFound)
 		return
 	}
 
-	vector, err := generateVector(string(content))
+	var report Report
+	if isArchive(req.FileName) {
+		var files []archiveEntry
+		var skipped []string
+		files, skipped, err = unpackArchive(req.FileName, content)
+		if err != nil {
+			log.Printf("Error unpacking archive %s: %v", req.FileName, err)
+			http.Error(w, "Unsafe or corrupt archive", http.StatusUnprocessableEntity)
+			return
+		}
+		report, err = analyzeArchive(req, files, skipped)
+	} else {
+		var doc Document
+		doc, err = extractTextCached(req.FileName, content)
+		if err != nil {
+			log.Printf("Error extracting text from %s: %v", req.FileName, err)
+			http.Error(w, "Unsupported or corrupt document", http.StatusUnprocessableEntity)
+			return
+		}
+		report, err = analyzeSubmission(req, doc)
+	}
+	// err общий для обеих веток: ошибка анализа не должна превращаться в сохранённый отчёт
 	if err != nil {
-		log.Printf("Error generating vector: %v", err)
-		http.Error(w, "Failed to process document", http.StatusInternalServerError)
+		log.Printf("Error analyzing %s: %v", req.FileName, err)
+		http.Error(w, "Failed to analyze document", http.StatusInternalServerError)
 		return
 	}
 
-	_, err = storeDocument(req.Sender, req.WorkID, req.FileName, vector)
+	reportPath, err := saveReport(report)
 	if err != nil {
-		log.Printf("Error storing document: %v", err)
-		http.Error(w, "Failed to store document", http.StatusInternalServerError)
+		log.Printf("Error saving report: %v", err)
+	} else if report.EmbeddingMethod == hashingVectorName {
+		queueRescore(reportPath)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// handleGetSubmission — GET /submissions/{id}: сведения о сдаче без текста, чтобы gateway мог
+// проверить права до дорогого выравнивания
+func handleGetSubmission(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	matches, err := findSimilarDocuments(req.WorkID, req.Sender, vector)
+	id := strings.TrimPrefix(r.URL.Path, "/submissions/")
+	sub, err := loadSubmission(id)
 	if err != nil {
-		log.Printf("Error finding similar documents: %v", err)
-		http.Error(w, "Failed to analyze document", http.StatusInternalServerError)
+		log.Printf("Error loading submission %s: %v", id, err)
+		http.Error(w, "Submission not found", http.StatusNotFound)
 		return
 	}
 
-	var similarity float64
-	if len(matches) > 0 {
-		similarity = matches[0].Score
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"id":        sub.ID,
+		"sender":    sub.Sender,
+		"work_id":   sub.WorkID,
+		"file_name": sub.FileName,
+		"timestamp": sub.Timestamp,
+	}); err != nil {
+		log.Printf("Error encoding response: %v", err)
 	}
+}
 
-	report := Report{
-		FileName:    req.FileName,
-		Sender:      req.Sender,
-		WorkID:      req.WorkID,
-		Plagiarized: len(matches) > 0,
-		Similarity:  similarity,
-		Timestamp:   getCurrentTime(),
+// handleAlign — GET /align?a={submission_id}&b={submission_id}[&with_text=true]
+func handleAlign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	if err := saveReport(report); err != nil {
-		log.Printf("Error saving report: %v", err)
+	query := r.URL.Query()
+	idA, idB := query.Get("a"), query.Get("b")
+	if idA == "" || idB == "" {
+		http.Error(w, "Both submission IDs are required", http.StatusBadRequest)
+		return
 	}
 
-	imagePath, err := downloadAndSaveWordCloud(string(content))
+	subA, err := loadSubmission(idA)
 	if err != nil {
-		log.Printf("Error generating word cloud: %v", err)
-		w.Header().Set("Content-Type", "application/json")
-		response := map[string]interface{}{
-			"similarity":  similarity,
-			"plagiarized": len(matches) > 0,
-			"error":       "Failed to generate word cloud",
-		}
-		json.NewEncoder(w).Encode(response)
+		log.Printf("Error loading submission %s: %v", idA, err)
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	subB, err := loadSubmission(idB)
+	if err != nil {
+		log.Printf("Error loading submission %s: %v", idB, err)
+		http.Error(w, "Submission not found", http.StatusNotFound)
 		return
 	}
-	defer os.Remove(imagePath)
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Content-Disposition", "attachment; filename=wordcloud.png")
+	alignment := alignSubmissions(subA, subB, query.Get("with_text") == "true")
 
-	imageData, err := ioutil.ReadFile(imagePath)
-	if err != nil {
-		log.Printf("Error reading image file: %v", err)
-		http.Error(w, "Failed to read image", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(alignment); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func handleGetWordCloud(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := filepath.Base(r.URL.Path)
+	if !strings.HasPrefix(name, "wordcloud_") || !strings.HasSuffix(name, ".png") {
+		http.Error(w, "Invalid word cloud name", http.StatusBadRequest)
+		return
+	}
+
+	path := filepath.Join(config.WordCloudDir, name)
+	if _, err := os.Stat(path); err != nil {
+		http.Error(w, "Word cloud not found", http.StatusNotFound)
 		return
 	}
 
-	w.Write(imageData)
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, path)
 }
 
 func handleGetReports(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Language — конвейер обработки слов одного языка: алфавит, стеммер (nil — слова не
+// сводятся к основам) и стоп-слова, которые не попадают в облако слов
+type Language struct {
+	Code      string
+	Script    *unicode.RangeTable
+	Stem      func(string) string
+	StopWords map[string]bool
+}
+
+// languageSpan — участок текста (смещения в символах) на одном языке
+type languageSpan struct {
+	Start, End int
+	Language   string
+}
+
+const (
+	languageMinLetters = 20   // меньше букв — язык не определяется
+	languageSampleSize = 4096 // сколько символов фрагмента учитывается при определении
+)
+
+var languages = map[string]*Language{
+	"ru": {Code: "ru", Script: unicode.Cyrillic, Stem: stemRussian, StopWords: wordSet(
+		"что это как то все если он она оно они мы вы я и или но не да нет в на за по от с со к ко из у о об " +
+			"для до при без над под про так же ли бы был была были было быть есть этот эта эти тот та те " +
+			"его её их ему ей им который которая которые которых также только уже ещё еще может можно")},
+	"uk": {Code: "uk", Script: unicode.Cyrillic, StopWords: wordSet(
+		"що це як то все якщо він вона воно вони ми ви я і й та або але не так ні в у на за по від з із до " +
+			"для при без над під про же чи би був була були було бути є цей ця ці той його її їх йому їй " +
+			"який яка які також тільки вже ще може можна")},
+	"en": {Code: "en", Script: unicode.Latin, Stem: stemEnglish, StopWords: wordSet(
+		"the a an and or but in on at to for of is are was were be been have has had do does did will " +
+			"would could should may might can this that these those i you he she it we they with from by " +
+			"as not no so if then than which who what its their our also")},
+	"de": {Code: "de", Script: unicode.Latin, StopWords: wordSet(
+		"der die das den dem des ein eine einen einem einer und oder aber nicht in im an am auf zu zum " +
+			"zur für von vom mit bei aus ist sind war waren wird werden hat haben sich es er sie wir ich " +
+			"auch als wie dass so nur noch")},
+	"fr": {Code: "fr", Script: unicode.Latin, StopWords: wordSet(
+		"le la les un une des du de et ou mais ne pas en dans sur pour par avec est sont était être a ont " +
+			"ce cette ces il elle ils elles nous vous je que qui au aux se son sa ses plus aussi comme")},
+}
+
+// Язык по умолчанию для слов алфавита, не совпадающего с алфавитом участка
+// (английские термины в русском тексте)
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	code   string
+}{
+	{unicode.Cyrillic, "ru"},
+	{unicode.Latin, "en"},
+}
+
+// Образцы текстов, по которым строятся триграммные модели языков
+var languageSamples = map[string]string{
+	"ru": `В данной работе рассматривается задача поиска заимствований в студенческих работах. Для её
+решения предлагается алгоритм, который сравнивает тексты по отпечаткам и по смыслу. Сначала документ
+разбивается на фрагменты, затем для каждого фрагмента вычисляется вектор, и похожие фрагменты
+находятся в базе данных. Результаты эксперимента показывают, что метод позволяет обнаружить
+перефразированные отрывки, которые не находятся при простом сравнении строк. Кроме того, были
+исследованы ограничения подхода: короткие ответы и формулы почти не содержат слов, поэтому их
+проверка требует других средств. В заключение описаны направления дальнейшей работы и выводы.`,
+	"uk": `У цій роботі розглядається задача пошуку запозичень у студентських роботах. Для її
+розв'язання пропонується алгоритм, який порівнює тексти за відбитками та за змістом. Спочатку
+документ розбивається на фрагменти, потім для кожного фрагмента обчислюється вектор, і схожі
+фрагменти знаходяться в базі даних. Результати експерименту показують, що метод дозволяє виявити
+перефразовані уривки, які не знаходяться під час простого порівняння рядків. Крім того, були
+досліджені обмеження підходу: короткі відповіді та формули майже не містять слів, тому їхня
+перевірка потребує інших засобів. На завершення описано напрями подальшої роботи та висновки.`,
+	"en": `This paper considers the problem of finding borrowed text in student assignments. To solve
+it, we propose an algorithm that compares documents both by fingerprints and by meaning. First, the
+document is split into fragments, then a vector is computed for each fragment, and similar fragments
+are found in the database. The results of the experiment show that the method is able to detect
+paraphrased passages which are not found by a simple comparison of strings. In addition, the
+limitations of the approach were studied: short answers and formulas contain almost no words, so
+checking them requires other tools. Finally, we describe the directions of further work and conclusions.`,
+	"de": `Diese Arbeit behandelt das Problem, übernommene Textstellen in studentischen Arbeiten zu
+finden. Zur Lösung wird ein Algorithmus vorgeschlagen, der die Dokumente sowohl nach Fingerabdrücken
+als auch nach ihrer Bedeutung vergleicht. Zuerst wird das Dokument in Fragmente zerlegt, dann wird für
+jedes Fragment ein Vektor berechnet, und ähnliche Fragmente werden in der Datenbank gesucht. Die
+Ergebnisse des Experiments zeigen, dass die Methode umformulierte Abschnitte erkennen kann, die bei
+einem einfachen Vergleich der Zeichenketten nicht gefunden werden. Außerdem wurden die Grenzen des
+Ansatzes untersucht. Zum Schluss werden die Richtungen der weiteren Arbeit und die Ergebnisse beschrieben.`,
+	"fr": `Ce travail examine le problème de la recherche des emprunts dans les travaux des étudiants.
+Pour le résoudre, nous proposons un algorithme qui compare les documents à la fois par leurs
+empreintes et par leur sens. D'abord, le document est découpé en fragments, puis un vecteur est
+calculé pour chaque fragment, et les fragments semblables sont recherchés dans la base de données.
+Les résultats de l'expérience montrent que la méthode permet de détecter des passages reformulés qui
+ne sont pas trouvés par une simple comparaison des chaînes. De plus, les limites de l'approche ont
+été étudiées. Enfin, nous décrivons les directions des travaux futurs et les conclusions.`,
+}
+
+// languageModel — частоты триграмм букв образца (слова дополняются пробелами по краям)
+type languageModel struct {
+	counts map[string]int
+	total  int
+}
+
+var (
+	languageModels     = make(map[string]languageModel)
+	languageVocabulary int // число различных триграмм всех моделей, для сглаживания
+)
+
+func init() {
+	vocabulary := make(map[string]bool)
+	for code, sample := range languageSamples {
+		m := languageModel{counts: trigrams(sample)}
+		for g, n := range m.counts {
+			m.total += n
+			vocabulary[g] = true
+		}
+		languageModels[code] = m
+	}
+	languageVocabulary = len(vocabulary)
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// loadStopWords заменяет встроенные списки стоп-слов файлами {язык}.txt из каталога
+// STOPWORDS_DIR: по слову в строке, строки с # пропускаются
+func loadStopWords(dir string) error {
+	for code, lang := range languages {
+		f, err := os.Open(filepath.Join(dir, code+".txt"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open stop words for %s: %w", code, err)
+		}
+
+		words := make(map[string]bool)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if word != "" && !strings.HasPrefix(word, "#") {
+				words[word] = true
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read stop words for %s: %w", code, err)
+		}
+		lang.StopWords = words
+	}
+	return nil
+}
+
+// trigrams считает триграммы букв в первых languageSampleSize символах текста
+func trigrams(text string) map[string]int {
+	counts := make(map[string]int)
+	word := []rune{' '}
+	flush := func() {
+		if len(word) > 1 {
+			word = append(word, ' ')
+			for i := 0; i+3 <= len(word); i++ {
+				counts[string(word[i:i+3])]++
+			}
+		}
+		word = word[:1]
+	}
+
+	n := 0
+	for _, r := range text {
+		if n++; n > languageSampleSize {
+			break
+		}
+		if unicode.IsLetter(r) {
+			word = append(word, unicode.ToLower(r))
+		} else if r != '\'' && r != '’' {
+			flush()
+		}
+	}
+	flush()
+	return counts
+}
+
+// detectLanguage определяет язык текста наивным байесовским классификатором по триграммам
+// среди языков его основного алфавита. Пустая строка — букв слишком мало или алфавит незнаком.
+func detectLanguage(text string) string {
+	scripts := make(map[*unicode.RangeTable]int)
+	letters := 0
+	n := 0
+	for _, r := range text {
+		if n++; n > languageSampleSize {
+			break
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				scripts[s.script]++
+			}
+		}
+	}
+	if letters < languageMinLetters {
+		return ""
+	}
+
+	var script *unicode.RangeTable
+	for _, s := range scriptLanguages {
+		if scripts[s.script]*2 > letters {
+			script = s.script
+		}
+	}
+	if script == nil {
+		return ""
+	}
+
+	counts := trigrams(text)
+	best, bestScore := "", math.Inf(-1)
+	for code, lang := range languages {
+		m, ok := languageModels[code]
+		if !ok || lang.Script != script {
+			continue
+		}
+		score := 0.0
+		for g, n := range counts {
+			score += float64(n) * math.Log(float64(m.counts[g]+1)/float64(m.total+languageVocabulary))
+		}
+		if score > bestScore || score == bestScore && code < best {
+			best, bestScore = code, score
+		}
+	}
+	return best
+}
+
+// detectLanguages определяет язык каждого окна текста (по CHUNK_WORDS слов) и основной язык
+// документа — язык, на котором написано больше всего символов. Окна, язык которых не
+// определился, относятся к основному языку; соседние участки одного языка сливаются.
+func detectLanguages(text string) (string, []languageSpan) {
+	windows := splitChunks(text, config.ChunkWords, 0)
+	spans := make([]languageSpan, len(windows))
+	size := make(map[string]int)
+	for i, w := range windows {
+		spans[i] = languageSpan{Start: w.Start, End: w.End, Language: detectLanguage(w.Text)}
+		if spans[i].Language != "" {
+			size[spans[i].Language] += w.End - w.Start
+		}
+	}
+
+	dominant := ""
+	for code, n := range size {
+		if n > size[dominant] || n == size[dominant] && code < dominant {
+			dominant = code
+		}
+	}
+	if dominant == "" {
+		return "", nil
+	}
+
+	var merged []languageSpan
+	for _, s := range spans {
+		if s.Language == "" {
+			s.Language = dominant
+		}
+		if k := len(merged) - 1; k >= 0 && merged[k].Language == s.Language {
+			merged[k].End = s.End
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return dominant, merged
+}
+
+// spanLanguages — языки участков в порядке убывания их объёма
+func spanLanguages(spans []languageSpan) []string {
+	size := make(map[string]int)
+	var codes []string
+	for _, s := range spans {
+		if _, ok := size[s.Language]; !ok {
+			codes = append(codes, s.Language)
+		}
+		size[s.Language] += s.End - s.Start
+	}
+	sort.SliceStable(codes, func(i, j int) bool { return size[codes[i]] > size[codes[j]] })
+	return codes
+}
+
+// languageAt — язык участка, в который попадает смещение pos
+func languageAt(spans []languageSpan, pos int) string {
+	i := sort.Search(len(spans), func(i int) bool { return spans[i].End > pos })
+	if i < len(spans) && spans[i].Start <= pos {
+		return spans[i].Language
+	}
+	return ""
+}
+
+// languageFor выбирает конвейер для слова: язык участка, если слово написано его алфавитом,
+// иначе язык по умолчанию для алфавита слова. Слова из разных алфавитов или с цифрами — nil.
+func languageFor(word, code string) *Language {
+	var script *unicode.RangeTable
+	for _, r := range word {
+		var s *unicode.RangeTable
+		for _, sl := range scriptLanguages {
+			if unicode.Is(sl.script, r) {
+				s = sl.script
+			}
+		}
+		if s == nil || script != nil && s != script {
+			return nil
+		}
+		script = s
+	}
+
+	if lang, ok := languages[code]; ok && lang.Script == script {
+		return lang
+	}
+	for _, sl := range scriptLanguages {
+		if sl.script == script {
+			return languages[sl.code]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Команды-разделы; заголовок становится метаданными блоков
+var latexSections = map[string]bool{
+	"part": true, "chapter": true, "section": true, "subsection": true, "subsubsection": true,
+}
+
+// Команды, которые удаляются вместе с аргументами (число обязательных аргументов)
+var latexDropCommands = map[string]int{
+	"label": 1, "ref": 1, "eqref": 1, "autoref": 1, "cref": 1, "Cref": 1, "pageref": 1,
+	"cite": 1, "citep": 1, "citet": 1, "nocite": 1, "url": 1, "includegraphics": 1,
+	"input": 1, "include": 1, "bibliography": 1, "bibliographystyle": 1,
+	"usepackage": 1, "documentclass": 1, "newcommand": 2, "renewcommand": 2, "providecommand": 2,
+	"newenvironment": 3, "renewenvironment": 3, "DeclareMathOperator": 2,
+	"setlength": 2, "addtolength": 2, "setcounter": 2, "addtocounter": 2,
+	"vspace": 1, "hspace": 1, "pagestyle": 1, "thispagestyle": 1, "hypersetup": 1,
+	"graphicspath": 1, "definecolor": 3, "color": 1, "textcolor": 1, "colorbox": 1,
+	"geometry": 1, "lstset": 1, "addcontentsline": 3, "bibitem": 1, "href": 1,
+	"title": 1, "author": 1, "date": 1,
+}
+
+// Команды, заменяемые текстом
+var latexSymbols = map[string]string{
+	"LaTeX": "LaTeX", "TeX": "TeX", "ldots": "…", "dots": "…", "textendash": "–", "textemdash": "—",
+	"S": "§", "par": "\n\n", "item": "\n\n", "newline": " ", "linebreak": " ", "quad": " ", "qquad": " ",
+	"textbackslash": "\\", "guillemotleft": "«", "guillemotright": "»", "No": "№",
+	"footnote": " ",
+}
+
+// Окружения, содержимое которых не является прозой
+var latexDropEnvironments = map[string]bool{
+	"equation": true, "equation*": true, "align": true, "align*": true, "gather": true, "gather*": true,
+	"multline": true, "multline*": true, "eqnarray": true, "eqnarray*": true, "math": true,
+	"displaymath": true, "flalign": true, "flalign*": true, "verbatim": true, "Verbatim": true,
+	"lstlisting": true, "minted": true, "comment": true, "tikzpicture": true, "tabular": true,
+	"tabular*": true, "tabularx": true, "thebibliography": true, "algorithmic": true,
+}
+
+// Число аргументов у \begin{...} сохраняемых окружений
+var latexEnvironmentArgs = map[string]int{"minipage": 1, "multicols": 1, "wrapfigure": 2}
+
+type latexScanner struct {
+	src []rune
+	pos int
+}
+
+// extractLatex оставляет от LaTeX-исходника только прозу: команды, окружения с формулами
+// и кодом, математика и комментарии удаляются, заголовки разделов сохраняются как метаданные
+func extractLatex(src string) Document {
+	if i := strings.Index(src, `\begin{document}`); i >= 0 {
+		src = src[i+len(`\begin{document}`):]
+		if j := strings.Index(src, `\end{document}`); j >= 0 {
+			src = src[:j]
+		}
+	}
+
+	var b sectionBuilder
+	var para strings.Builder
+	s := &latexScanner{src: []rune(src)}
+	s.scan(&para, func(title string) {
+		b.addParagraphs(para.String())
+		para.Reset()
+		b.startSection(title)
+	})
+	b.addParagraphs(para.String())
+	return b.document("latex")
+}
+
+// latexInline — текст фрагмента разметки без разбиения на абзацы (для заголовков)
+func latexInline(src string) string {
+	var out strings.Builder
+	s := &latexScanner{src: []rune(src)}
+	s.scan(&out, func(string) {})
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(out.String(), " "))
+}
+
+func (s *latexScanner) peek(offset int) rune {
+	if s.pos+offset < len(s.src) {
+		return s.src[s.pos+offset]
+	}
+	return 0
+}
+
+func (s *latexScanner) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(s.src[s.pos:min(s.pos+len([]rune(prefix)), len(s.src))]), prefix)
+}
+
+// skipTo переносит позицию за ближайшее вхождение marker (или в конец)
+func (s *latexScanner) skipTo(marker string) {
+	m := []rune(marker)
+	for s.pos < len(s.src) {
+		if m[0] != '\\' && s.src[s.pos] == '\\' {
+			s.pos += 2 // экранированный символ, например \$ внутри формулы
+			continue
+		}
+		if s.hasPrefix(marker) {
+			s.pos += len(m)
+			return
+		}
+		s.pos++
+	}
+}
+
+func (s *latexScanner) skipSpaces() {
+	for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+		s.pos++
+	}
+}
+
+// group читает аргумент: {...} с учётом вложенности, команду или один символ
+func (s *latexScanner) group() string {
+	s.skipSpaces()
+	if s.pos >= len(s.src) {
+		return ""
+	}
+	switch s.src[s.pos] {
+	case '{':
+		start := s.pos + 1
+		depth := 0
+		for ; s.pos < len(s.src); s.pos++ {
+			switch s.src[s.pos] {
+			case '\\':
+				s.pos++
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					s.pos++
+					return string(s.src[start : s.pos-1])
+				}
+			}
+		}
+		return string(s.src[start:])
+	case '\\':
+		start := s.pos
+		s.pos++
+		for s.pos < len(s.src) && unicode.IsLetter(s.src[s.pos]) {
+			s.pos++
+		}
+		return string(s.src[start:s.pos])
+	}
+	s.pos++
+	return string(s.src[s.pos-1])
+}
+
+// optional пропускает необязательный аргумент [...]
+func (s *latexScanner) optional() string {
+	save := s.pos
+	s.skipSpaces()
+	if s.peek(0) != '[' {
+		s.pos = save
+		return ""
+	}
+	start := s.pos + 1
+	depth := 0
+	for ; s.pos < len(s.src); s.pos++ {
+		switch s.src[s.pos] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				s.pos++
+				return string(s.src[start : s.pos-1])
+			}
+		}
+	}
+	return ""
+}
+
+func (s *latexScanner) scan(out *strings.Builder, section func(title string)) {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '%':
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.pos++
+			}
+			// Комментарий съедает перевод строки и отступ следующей строки
+			s.pos++
+			s.skipSpaces()
+
+		case c == '$':
+			if s.peek(1) == '$' {
+				s.pos += 2
+				s.skipTo("$$")
+			} else {
+				s.pos++
+				s.skipTo("$")
+			}
+			out.WriteByte(' ')
+
+		case c == '\\':
+			s.command(out, section)
+
+		case c == '{' || c == '}' || c == '&' || c == '^' || c == '_':
+			s.pos++
+
+		case c == '~':
+			out.WriteByte(' ')
+			s.pos++
+
+		case c == '\n':
+			// Пустая строка — конец абзаца
+			j := s.pos + 1
+			for j < len(s.src) && (s.src[j] == ' ' || s.src[j] == '\t' || s.src[j] == '\r') {
+				j++
+			}
+			if j < len(s.src) && s.src[j] == '\n' {
+				out.WriteString("\n\n")
+				s.pos = j + 1
+			} else {
+				out.WriteByte(' ')
+				s.pos++
+			}
+
+		case s.hasPrefix("---"):
+			out.WriteString("—")
+			s.pos += 3
+		case s.hasPrefix("--"):
+			out.WriteString("–")
+			s.pos += 2
+		case s.hasPrefix("``"):
+			out.WriteString("“")
+			s.pos += 2
+		case s.hasPrefix("''"):
+			out.WriteString("”")
+			s.pos += 2
+
+		default:
+			out.WriteRune(c)
+			s.pos++
+		}
+	}
+}
+
+func (s *latexScanner) command(out *strings.Builder, section func(title string)) {
+	s.pos++
+	if s.pos >= len(s.src) {
+		return
+	}
+
+	// Управляющие символы: \\, \%, \', \( ... \), \[ ... \]
+	if c := s.src[s.pos]; !unicode.IsLetter(c) {
+		s.pos++
+		switch c {
+		case '(':
+			s.skipTo(`\)`)
+			out.WriteByte(' ')
+		case '[':
+			s.skipTo(`\]`)
+			out.WriteByte(' ')
+		case '%', '&', '$', '#', '_', '{', '}':
+			out.WriteRune(c)
+		case '\\', ',', ';', ':', ' ', '\n':
+			out.WriteByte(' ')
+		}
+		return
+	}
+
+	start := s.pos
+	for s.pos < len(s.src) && unicode.IsLetter(s.src[s.pos]) {
+		s.pos++
+	}
+	name := string(s.src[start:s.pos])
+	if s.peek(0) == '*' {
+		s.pos++
+	}
+
+	switch {
+	case name == "begin":
+		env := s.group()
+		if latexDropEnvironments[env] {
+			s.skipTo(`\end{` + env + `}`)
+		} else {
+			s.optional()
+			for i := 0; i < latexEnvironmentArgs[env]; i++ {
+				s.group()
+			}
+		}
+		out.WriteString("\n\n")
+
+	case name == "end":
+		s.group()
+		out.WriteString("\n\n")
+
+	case latexSections[name]:
+		s.optional()
+		section(latexInline(s.group()))
+
+	case latexDropCommands[name] > 0:
+		s.optional()
+		for i := 0; i < latexDropCommands[name]; i++ {
+			s.optional()
+			s.group()
+		}
+
+	case latexSymbols[name] != "":
+		out.WriteString(latexSymbols[name])
+		s.skipSpaces()
+
+	default:
+		// Прочие команды (\emph, \textbf, \footnote, ...) удаляются, а их аргументы
+		// в фигурных скобках остаются текстом
+		s.optional()
+		s.skipSpaces()
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Максимальный разрыв (в символах) между совпавшими отпечатками внутри одного фрагмента
+const lexicalPassageGap = 200
+
+// Версия словесных отпечатков; растёт при смене нормализации слов (1 — основы вместо словоформ).
+// Записи других версий с новыми несравнимы и пересчитываются по тексту сдачи.
+const lexicalFingerprintVersion = 1
+
+// fingerprintRecord — отпечатки одной сдачи, хранятся в {base}/{work_id}/{submission_id}.json
+type fingerprintRecord struct {
+	SubmissionID string        `json:"submission_id"`
+	Sender       string        `json:"sender"`
+	WorkID       string        `json:"work_id"`
+	FileName     string        `json:"file_name"`
+	Timestamp    string        `json:"timestamp"`
+	Version      int           `json:"version,omitempty"`
+	Fingerprints []Fingerprint `json:"fingerprints"`
+}
+
+type LexicalMatch struct {
+	ID       string    `json:"id"`
+	FileName string    `json:"file_name"`
+	Sender   string    `json:"sender"`
+	Overlap  float64   `json:"overlap"`
+	Passages []Passage `json:"passages,omitempty"`
+}
+
+func workDir(base, workID string) (string, error) {
+	if workID == "" || workID != filepath.Base(workID) || strings.HasPrefix(workID, ".") {
+		return "", fmt.Errorf("invalid work id %q", workID)
+	}
+	return filepath.Join(base, workID), nil
+}
+
+// saveWorkRecord сохраняет запись сдачи в {base}/{work_id}/{submission_id}.json
+func saveWorkRecord(base, workID, submissionID string, rec interface{}) error {
+	dir, err := workDir(base, workID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	path := filepath.Join(dir, submissionID+".json")
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadWorkRecords передаёт decode содержимое каждой записи задания; нечитаемые и
+// неразобранные записи пропускаются
+func loadWorkRecords(base, workID string, decode func(data []byte) error) error {
+	dir, err := workDir(base, workID)
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			log.Printf("Error reading %s: %v", filepath.Join(dir, file.Name()), err)
+			continue
+		}
+		if err := decode(data); err != nil {
+			log.Printf("Error parsing %s: %v", filepath.Join(dir, file.Name()), err)
+		}
+	}
+	return nil
+}
+
+func saveFingerprints(base string, rec fingerprintRecord) error {
+	return saveWorkRecord(base, rec.WorkID, rec.SubmissionID, rec)
+}
+
+func loadFingerprints(base, workID string) ([]fingerprintRecord, error) {
+	var records []fingerprintRecord
+	err := loadWorkRecords(base, workID, func(data []byte) error {
+		var rec fingerprintRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+// findLexicalMatches сравнивает отпечатки сдачи с остальными сдачами задания (кроме своих).
+// Overlap — доля отпечатков проверяемой работы, встретившихся в другой работе.
+func findLexicalMatches(rec fingerprintRecord, limit int) ([]LexicalMatch, error) {
+	others, err := loadFingerprints(config.FingerprintDir, rec.WorkID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []LexicalMatch
+	for _, other := range others {
+		if other.SubmissionID == rec.SubmissionID || other.Sender == rec.Sender {
+			continue
+		}
+		if other.Version != lexicalFingerprintVersion {
+			if err := upgradeLexicalFingerprints(&other); err != nil {
+				log.Printf("Skipping stale fingerprints of submission %s: %v", other.SubmissionID, err)
+				continue
+			}
+		}
+
+		overlap, passages := compareFingerprints(rec.Fingerprints, other.Fingerprints, lexicalPassageGap)
+		if overlap == 0 {
+			continue
+		}
+		matches = append(matches, LexicalMatch{
+			ID:       other.SubmissionID,
+			FileName: other.FileName,
+			Sender:   other.Sender,
+			Overlap:  overlap,
+			Passages: passages,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Overlap > matches[j].Overlap })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// compareFingerprints возвращает долю общих отпечатков и совпавшие фрагменты;
+// отпечатки, отстоящие друг от друга не более чем на gap, сливаются в один фрагмент
+func compareFingerprints(mine, other []Fingerprint, gap int) (float64, []Passage) {
+	if len(mine) == 0 {
+		return 0, nil
+	}
+
+	otherPos := make(map[uint64]Fingerprint, len(other))
+	for _, fp := range other {
+		if _, ok := otherPos[fp.Hash]; !ok {
+			otherPos[fp.Hash] = fp
+		}
+	}
+
+	distinct := make(map[uint64]bool, len(mine))
+	shared := make(map[uint64]bool)
+	var passages []Passage
+	var matched []int
+
+	for _, fp := range mine {
+		distinct[fp.Hash] = true
+		o, ok := otherPos[fp.Hash]
+		if !ok {
+			continue
+		}
+		shared[fp.Hash] = true
+
+		if n := len(passages); n > 0 {
+			last := &passages[n-1]
+			if fp.Start-last.End <= gap && o.Start-last.OtherEnd <= gap && last.OtherStart-o.End <= gap {
+				last.End = max(last.End, fp.End)
+				last.OtherStart = min(last.OtherStart, o.Start)
+				last.OtherEnd = max(last.OtherEnd, o.End)
+				matched[n-1]++
+				continue
+			}
+		}
+		passages = append(passages, Passage{Start: fp.Start, End: fp.End, OtherStart: o.Start, OtherEnd: o.End})
+		matched = append(matched, 1)
+	}
+
+	// Score фрагмента — доля совпавших отпечатков среди всех отпечатков внутри него
+	for i := range passages {
+		total := 0
+		for _, fp := range mine {
+			if fp.Start >= passages[i].Start && fp.End <= passages[i].End {
+				total++
+			}
+		}
+		passages[i].Score = float64(matched[i]) / float64(max(total, 1))
+	}
+
+	return float64(len(shared)) / float64(len(distinct)), passages
+}
+
+// upgradeLexicalFingerprints пересчитывает отпечатки записи старой версии по сохранённому
+// тексту сдачи и перезаписывает её
+func upgradeLexicalFingerprints(rec *fingerprintRecord) error {
+	sub, err := loadSubmission(rec.SubmissionID)
+	if err != nil {
+		return err
+	}
+
+	_, spans := detectLanguages(sub.Text)
+	rec.Fingerprints = lexicalFingerprints(sub.Text, spans)
+	rec.Version = lexicalFingerprintVersion
+	return saveFingerprints(config.FingerprintDir, *rec)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	whitespacePattern     = regexp.MustCompile(`\s+`)
+	punctuationGapPattern = regexp.MustCompile(`\s+([.,;:!?])`)
+)
+
+// Block — участок извлечённого текста и его место в исходном документе
+type Block struct {
+	Start     int     `json:"start"` // смещения в символах в тексте документа
+	End       int     `json:"end"`
+	Page      int     `json:"page,omitempty"`
+	Section   string  `json:"section,omitempty"`
+	Paragraph int     `json:"paragraph,omitempty"` // номер абзаца на странице или в разделе
+	Cell      int     `json:"cell,omitempty"`      // номер ячейки блокнота Jupyter, с единицы
+	X         float64 `json:"x,omitempty"`         // левый верхний угол блока в пунктах
+	Y         float64 `json:"y,omitempty"`         // от верхнего левого угла страницы
+}
+
+// Location — человекочитаемое место фрагмента: «страница 7, абзац 2» или «раздел Введение, абзац 3»
+type Location struct {
+	Page      int    `json:"page,omitempty"`
+	Section   string `json:"section,omitempty"`
+	Paragraph int    `json:"paragraph,omitempty"`
+	Cell      int    `json:"cell,omitempty"`
+}
+
+// locate возвращает блок, в который попадает смещение, или nil, если блоков нет
+func locate(blocks []Block, offset int) *Location {
+	i := sort.Search(len(blocks), func(i int) bool { return blocks[i].End > offset })
+	if i == len(blocks) {
+		return nil
+	}
+	return &Location{Page: blocks[i].Page, Section: blocks[i].Section, Paragraph: blocks[i].Paragraph, Cell: blocks[i].Cell}
+}
+
+// locatePassages дополняет совпавшие фрагменты отчёта местом в обоих документах
+func locatePassages(report *Report, blocks []Block) {
+	cache := make(map[string][]Block)
+	otherBlocks := func(id string) []Block {
+		if b, ok := cache[id]; ok {
+			return b
+		}
+		sub, err := loadSubmission(id)
+		cache[id] = sub.Blocks
+		if err != nil {
+			return nil
+		}
+		return sub.Blocks
+	}
+
+	fill := func(id string, passages []Passage) {
+		for i := range passages {
+			passages[i].Location = locate(blocks, passages[i].Start)
+			passages[i].OtherLocation = locate(otherBlocks(id), passages[i].OtherStart)
+		}
+	}
+	for _, m := range report.Matches {
+		fill(m.ID, m.Passages)
+	}
+	for _, m := range report.LexicalMatches {
+		fill(m.ID, m.Passages)
+	}
+}
+
+// sectionBuilder собирает текст из абзацев с разметкой разделов: заголовки не попадают
+// в текст, а остаются метаданными блоков
+type sectionBuilder struct {
+	text      strings.Builder
+	blocks    []Block
+	sections  []string
+	section   string
+	paragraph int
+	cell      int
+	offset    int
+}
+
+func (b *sectionBuilder) startSection(title string) {
+	b.section = title
+	b.paragraph = 0
+	if title != "" {
+		b.sections = append(b.sections, title)
+	}
+}
+
+func (b *sectionBuilder) addParagraph(p string) {
+	// После удалённой разметки («см. \ref{...}.») перед знаками препинания остаются пробелы
+	p = punctuationGapPattern.ReplaceAllString(whitespacePattern.ReplaceAllString(p, " "), "$1")
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return
+	}
+	if b.offset > 0 {
+		b.text.WriteString("\n\n")
+		b.offset += 2
+	}
+	b.paragraph++
+	length := utf8.RuneCountInString(p)
+	b.blocks = append(b.blocks, Block{
+		Start:     b.offset,
+		End:       b.offset + length,
+		Section:   b.section,
+		Paragraph: b.paragraph,
+		Cell:      b.cell,
+	})
+	b.text.WriteString(p)
+	b.offset += length
+}
+
+// addParagraphs добавляет текст, в котором абзацы разделены пустой строкой
+func (b *sectionBuilder) addParagraphs(text string) {
+	for _, p := range strings.Split(text, "\n\n") {
+		b.addParagraph(p)
+	}
+}
+
+func (b *sectionBuilder) document(format string) Document {
+	return Document{Format: format, Text: b.text.String(), Blocks: b.blocks, Sections: b.sections}
+}
@@ -4,22 +4,108 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	QdrantURL           string
 	CollectionName      string
 	DataDir             string
+	WordCloudDir        string
+	FingerprintDir      string
+	CodeFingerprintDir  string
+	StructureDir        string
+	TextDir             string
+	MinHashIndexPath    string
 	SimilarityThreshold float64
+	SuspiciousThreshold float64
+	SearchLimit         int
+	MinCoverage         float64
+	ChunkWords          int
+	ChunkOverlap        int
+	WinnowK             int
+	WinnowW             int
+	CodeWinnowK         int
+	CodeWinnowW         int
+	MinHashThreshold    float64
+	MinHashScope        string
+	ArchiveMaxEntries   int
+	ArchiveMaxSize      int64
+	ArchiveMaxRatio     float64
+	ObfuscationMinChars int
+	LemmaDictPath       string
+	StopWordsDir        string
+	EmbeddingProvider   string
+	EmbeddingURL        string
+	EmbeddingModel      string
+	EmbeddingDim        int
+	EmbeddingAPIKey     string
+	EmbeddingBatchSize  int
+	EmbeddingBatchWait  time.Duration
+	EmbeddingWorkers    int
+	CacheDir            string
+	FallbackDim         int
+	FallbackSuspicious  float64
+	FallbackPlagiarized float64
+	CodeSuspicious      float64
+	CodePlagiarized     float64
+	RescoreDir          string
+	RescoreInterval     time.Duration
+	VectorStore         string
+	VectorStorePath     string
 }
 
 var config = Config{
 	QdrantURL:           getEnv("QDRANT_URL", "http://qdrant:6333"),
 	CollectionName:      getEnv("COLLECTION_NAME", "documents"),
 	DataDir:             getEnv("DATA_DIR", "/files/reports"),
-	SimilarityThreshold: 0.85,
+	WordCloudDir:        getEnv("WORDCLOUD_DIR", "/files/wordclouds"),
+	FingerprintDir:      getEnv("FINGERPRINT_DIR", "/files/fingerprints"),
+	CodeFingerprintDir:  getEnv("CODE_FINGERPRINT_DIR", "/files/code_fingerprints"),
+	StructureDir:        getEnv("STRUCTURE_DIR", "/files/structures"),
+	TextDir:             getEnv("TEXT_DIR", "/files/texts"),
+	MinHashIndexPath:    getEnv("MINHASH_INDEX", "/files/minhash/index.jsonl"),
+	SimilarityThreshold: getEnvFloat("SIMILARITY_THRESHOLD", 0.85),
+	SuspiciousThreshold: getEnvFloat("SUSPICIOUS_THRESHOLD", 0.7),
+	SearchLimit:         getEnvInt("SEARCH_LIMIT", 5),
+	MinCoverage:         getEnvFloat("MIN_COVERAGE", 0.1),
+	ChunkWords:          getEnvInt("CHUNK_WORDS", 200),
+	ChunkOverlap:        getEnvInt("CHUNK_OVERLAP", 50),
+	WinnowK:             getEnvInt("WINNOW_K", 5),
+	WinnowW:             getEnvInt("WINNOW_W", 4),
+	CodeWinnowK:         getEnvInt("CODE_WINNOW_K", 10),
+	CodeWinnowW:         getEnvInt("CODE_WINNOW_W", 6),
+	MinHashThreshold:    getEnvFloat("MINHASH_THRESHOLD", 0.5),
+	MinHashScope:        getEnv("MINHASH_SCOPE", minhashScopeWork),
+	ArchiveMaxEntries:   getEnvInt("ARCHIVE_MAX_ENTRIES", 1000),
+	ArchiveMaxSize:      int64(getEnvInt("ARCHIVE_MAX_SIZE", 100<<20)),
+	ArchiveMaxRatio:     getEnvFloat("ARCHIVE_MAX_RATIO", 100),
+	ObfuscationMinChars: getEnvInt("OBFUSCATION_MIN_CHARS", 3),
+	LemmaDictPath:       getEnv("LEMMA_DICT", ""),
+	StopWordsDir:        getEnv("STOPWORDS_DIR", ""),
+	EmbeddingProvider:   getEnv("EMBEDDING_PROVIDER", "embed"),
+	EmbeddingURL:        getEnv("EMBEDDING_URL", "http://embeddings:8003"),
+	EmbeddingModel:      getEnv("EMBEDDING_MODEL", "all-MiniLM-L6-v2"),
+	EmbeddingDim:        getEnvInt("EMBEDDING_DIM", 384),
+	EmbeddingAPIKey:     getEnv("EMBEDDING_API_KEY", ""),
+	EmbeddingBatchSize:  getEnvInt("EMBEDDING_BATCH_SIZE", 32),
+	EmbeddingBatchWait:  time.Duration(getEnvInt("EMBEDDING_BATCH_WAIT_MS", 20)) * time.Millisecond,
+	EmbeddingWorkers:    getEnvInt("EMBEDDING_WORKERS", 2),
+	CacheDir:            getEnv("CACHE_DIR", "/files/cache"),
+	FallbackDim:         getEnvInt("FALLBACK_DIM", 1024),
+	FallbackSuspicious:  getEnvFloat("FALLBACK_SUSPICIOUS_THRESHOLD", 0.5),
+	FallbackPlagiarized: getEnvFloat("FALLBACK_SIMILARITY_THRESHOLD", 0.7),
+	CodeSuspicious:      getEnvFloat("CODE_SUSPICIOUS_THRESHOLD", 0.3),
+	CodePlagiarized:     getEnvFloat("CODE_SIMILARITY_THRESHOLD", 0.5),
+	RescoreDir:          getEnv("RESCORE_DIR", "/files/rescore"),
+	RescoreInterval:     time.Duration(getEnvInt("RESCORE_INTERVAL_SEC", 60)) * time.Second,
+	VectorStore:         getEnv("VECTOR_STORE", "qdrant"),
+	VectorStorePath:     getEnv("VECTOR_STORE_PATH", "/files/vectors/points.jsonl"),
 }
 
+var minhashIndex *MinHashIndex
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -27,10 +113,34 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		log.Printf("Invalid value for %s: %q, using %v", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("Invalid value for %s: %q, using %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("Starting file analysis service...")
 
+	if err := checkThresholdConfig(); err != nil {
+		log.Fatalf("Invalid thresholds: %v", err)
+	}
+
 	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
@@ -39,12 +149,69 @@ func main() {
 		log.Fatalf("Failed to create reports directory: %v", err)
 	}
 
-	if err := initializeQdrant(); err != nil {
-		log.Fatalf("Failed to initialize Qdrant: %v", err)
+	if err := os.MkdirAll(config.WordCloudDir, 0755); err != nil {
+		log.Fatalf("Failed to create word cloud directory: %v", err)
+	}
+
+	if config.MinHashScope != minhashScopeWork && config.MinHashScope != minhashScopeCorpus {
+		log.Fatalf("Invalid MINHASH_SCOPE %q: must be %q or %q", config.MinHashScope, minhashScopeWork, minhashScopeCorpus)
+	}
+
+	var err error
+	if minhashIndex, err = openMinHashIndex(config.MinHashIndexPath); err != nil {
+		log.Fatalf("Failed to open MinHash index: %v", err)
+	}
+
+	if config.LemmaDictPath != "" {
+		if lemmas, err = loadLemmas(config.LemmaDictPath); err != nil {
+			log.Fatalf("Failed to load lemma dictionary: %v", err)
+		}
+		log.Printf("Loaded %d lemmas from %s", len(lemmas), config.LemmaDictPath)
+	}
+
+	if config.StopWordsDir != "" {
+		if err := loadStopWords(config.StopWordsDir); err != nil {
+			log.Fatalf("Failed to load stop words: %v", err)
+		}
+	}
+
+	if embedder, err = newEmbeddingProvider(config); err != nil {
+		log.Fatalf("Failed to configure embeddings: %v", err)
+	}
+	log.Printf("Using %s embeddings: model %s, dimension %d", config.EmbeddingProvider, embedder.Model(), embedder.Dimension())
+	if embeddings, err = newEmbeddingBatcher(embedder, config.EmbeddingBatchSize, config.EmbeddingBatchWait, config.EmbeddingWorkers); err != nil {
+		log.Fatalf("Failed to configure embeddings: %v", err)
+	}
+
+	if config.CacheDir != "" {
+		if cache, err = openContentCache(config.CacheDir, embeddingModelID(config)); err != nil {
+			log.Fatalf("Failed to open cache: %v", err)
+		}
+	}
+
+	if vectorStore, err = newVectorStore(config); err != nil {
+		log.Fatalf("Failed to open vector store: %v", err)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		if err := reindexSubmissions(); err != nil {
+			log.Fatalf("Reindex failed: %v", err)
+		}
+		return
+	}
+	if err := vectorStore.Init(collectionVectors()); err != nil {
+		log.Fatalf("Failed to initialize vector store: %v", err)
+	}
+
+	if err := os.MkdirAll(config.RescoreDir, 0755); err != nil {
+		log.Fatalf("Failed to create rescore directory: %v", err)
 	}
+	go runRescorer(config.RescoreInterval)
 
 	http.HandleFunc("/analyze", handleAnalyze)
 	http.HandleFunc("/reports/", handleGetReports)
+	http.HandleFunc("/wordclouds/", handleGetWordCloud)
+	http.HandleFunc("/submissions/", handleGetSubmission)
+	http.HandleFunc("/align", handleAlign)
 	http.HandleFunc("/health", handleHealthCheck)
 
 	log.Println("File analysis service is running on :8002")
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	mdHeadingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+	mdSetextPattern     = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	mdFencePattern      = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	mdLinkDefPattern    = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s+\S+`)
+	mdRulePattern       = regexp.MustCompile(`^ {0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	mdListPattern       = regexp.MustCompile(`^\s*([-*+]|\d+[.)])\s+(\[[ xX]\]\s+)?`)
+	mdQuotePattern      = regexp.MustCompile(`^\s*(>\s?)+`)
+	mdTableRulePattern  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdImagePattern      = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)|!\[[^\]]*\]\[[^\]]*\]`)
+	mdLinkPattern       = regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	mdAutolinkPattern   = regexp.MustCompile(`<(https?://|mailto:)[^>]*>`)
+	mdCodeSpanPattern   = regexp.MustCompile("`+[^`]*`+")
+	mdInlineMathPattern = regexp.MustCompile(`\$[^$\s][^$]*\$`)
+	mdHTMLTagPattern    = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	mdCommentPattern    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	mdEmphasisPattern   = regexp.MustCompile(`\*+|~~|(^|[^\p{L}\p{N}_])_+|_+([^\p{L}\p{N}_]|$)`)
+	mdEscapePattern     = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!|])")
+)
+
+// extractMarkdown оставляет от Markdown только прозу: код, формулы, ссылки и разметка
+// удаляются, заголовки становятся разделами
+func extractMarkdown(src string) Document {
+	var b sectionBuilder
+	b.addMarkdown(src)
+	return b.document("markdown")
+}
+
+// addMarkdown добавляет абзацы Markdown-текста в документ; разделы продолжаются из
+// предыдущего текста, пока не встретится новый заголовок
+func (b *sectionBuilder) addMarkdown(src string) {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = mdCommentPattern.ReplaceAllString(src, "")
+	lines := strings.Split(src, "\n")
+
+	// YAML-заголовок в начале файла
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+
+	var para []string
+	flush := func() {
+		b.addParagraph(strings.Join(para, " "))
+		para = para[:0]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case mdFencePattern.MatchString(line):
+			// Блок кода до закрывающей ограды того же вида
+			flush()
+			fence := strings.TrimSpace(mdFencePattern.FindStringSubmatch(line)[1])
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+			}
+
+		case trimmed == "$$" || strings.HasPrefix(trimmed, "$$") && !strings.HasSuffix(trimmed[2:], "$$"):
+			flush()
+			for i++; i < len(lines) && !strings.Contains(lines[i], "$$"); i++ {
+			}
+
+		case strings.HasPrefix(trimmed, "$$"):
+			flush() // однострочная формула $$...$$
+
+		case len(para) == 0 && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")) &&
+			!mdListPattern.MatchString(line):
+			// Блок кода с отступом
+
+		case mdHeadingPattern.MatchString(line):
+			flush()
+			b.startSection(markdownInline(mdHeadingPattern.FindStringSubmatch(line)[2]))
+
+		case len(para) > 0 && mdSetextPattern.MatchString(line):
+			// Заголовок, подчёркнутый === или ---
+			title := strings.Join(para, " ")
+			para = para[:0]
+			b.startSection(title)
+
+		case mdRulePattern.MatchString(line), mdLinkDefPattern.MatchString(line), mdTableRulePattern.MatchString(line) && strings.Contains(line, "-"):
+
+		default:
+			if mdListPattern.MatchString(line) {
+				// Каждый пункт списка — отдельный абзац
+				flush()
+				line = mdListPattern.ReplaceAllString(line, "")
+			}
+			line = mdQuotePattern.ReplaceAllString(line, "")
+			if strings.Contains(line, "|") {
+				line = strings.ReplaceAll(strings.Trim(strings.TrimSpace(line), "|"), "|", " ")
+			}
+			para = append(para, markdownInline(line))
+		}
+	}
+	flush()
+}
+
+// markdownInline удаляет строчную разметку, оставляя текст ссылок и выделений
+func markdownInline(s string) string {
+	s = mdCodeSpanPattern.ReplaceAllString(s, " ")
+	s = mdInlineMathPattern.ReplaceAllString(s, " ")
+	s = mdImagePattern.ReplaceAllString(s, "")
+	s = mdLinkPattern.ReplaceAllString(s, "$1")
+	s = mdAutolinkPattern.ReplaceAllString(s, "")
+	s = mdHTMLTagPattern.ReplaceAllString(s, "")
+	// Экранированные символы прячутся в область частного использования, чтобы \* не
+	// принималось за выделение
+	s = mdEscapePattern.ReplaceAllStringFunc(s, func(m string) string { return string(rune(0xE000) + rune(m[1])) })
+	s = mdEmphasisPattern.ReplaceAllString(s, "$1$2")
+	s = strings.Map(func(r rune) rune {
+		if r > 0xE000 && r < 0xE080 {
+			return r - 0xE000
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// memoryStore — хранилище векторов с поиском полным перебором, для небольших установок
+// и проверок без Qdrant. Если задан путь, операции дописываются в JSON Lines журнал
+// (как у MinHashIndex) и при открытии проигрываются заново.
+type memoryStore struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	vectors map[string]int
+	points  map[int64]VectorPoint
+	stale   int
+}
+
+// memoryOp — запись журнала: вставка точки или удаление точек по ID
+type memoryOp struct {
+	Upsert *VectorPoint `json:"upsert,omitempty"`
+	Delete []int64      `json:"delete,omitempty"`
+}
+
+// openMemoryStore открывает хранилище; пустой path — только в памяти
+func openMemoryStore(path string) (*memoryStore, error) {
+	m := &memoryStore{path: path, points: make(map[int64]VectorPoint)}
+	if path == "" {
+		return m, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create vector store directory: %w", err)
+	}
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var op memoryOp
+			if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+				log.Printf("Skipping corrupt vector store line: %v", err)
+				continue
+			}
+			m.apply(op)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read vector store: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open vector store: %w", err)
+	}
+
+	if m.stale > len(m.points) {
+		if err := m.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vector store for writing: %w", err)
+	}
+	m.file = f
+
+	log.Printf("Vector store loaded: %d points", len(m.points))
+	return m, nil
+}
+
+// apply выполняет операцию в памяти; вызывается под m.mu
+func (m *memoryStore) apply(op memoryOp) {
+	if p := op.Upsert; p != nil {
+		if _, ok := m.points[p.ID]; ok {
+			m.stale++
+		}
+		m.points[p.ID] = *p
+	}
+	for _, id := range op.Delete {
+		if _, ok := m.points[id]; ok {
+			delete(m.points, id)
+			m.stale++
+		}
+	}
+}
+
+// write дописывает операции в журнал и применяет их; вызывается под m.mu
+func (m *memoryStore) write(ops []memoryOp) error {
+	if m.file != nil {
+		w := bufio.NewWriter(m.file)
+		for _, op := range ops {
+			data, err := json.Marshal(op)
+			if err != nil {
+				return fmt.Errorf("failed to marshal vector store entry: %w", err)
+			}
+			w.Write(append(data, '\n'))
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("failed to append to vector store: %w", err)
+		}
+	}
+	for _, op := range ops {
+		m.apply(op)
+	}
+
+	if m.file != nil && m.stale > len(m.points) {
+		if err := m.compact(); err != nil {
+			log.Printf("Error compacting vector store: %v", err)
+		}
+	}
+	return nil
+}
+
+// compact переписывает журнал только актуальными точками; вызывается под m.mu
+func (m *memoryStore) compact() error {
+	tmp := m.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted vector store: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	for id := range m.points {
+		p := m.points[id]
+		data, err := json.Marshal(memoryOp{Upsert: &p})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write compacted vector store: %w", err)
+	}
+	f.Close()
+
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to replace vector store: %w", err)
+	}
+
+	if m.file != nil {
+		m.file.Close()
+		m.file, err = os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to reopen vector store: %w", err)
+		}
+	}
+	m.stale = 0
+	return nil
+}
+
+// Init запоминает размеры векторов; сохранённые точки другой размерности — ошибка,
+// как и у коллекции Qdrant
+func (m *memoryStore) Init(vectors map[string]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, p := range m.points {
+		for name, v := range p.Vectors {
+			if size, ok := vectors[name]; !ok || len(v) != size {
+				return fmt.Errorf("vector store %s has vector %q of size %d, expected %d; %s",
+					m.path, name, len(v), size, reindexHint)
+			}
+		}
+	}
+	m.vectors = vectors
+	return nil
+}
+
+func (m *memoryStore) Reset(vectors map[string]int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.points = make(map[int64]VectorPoint)
+	m.vectors = vectors
+	if m.file != nil {
+		return m.compact()
+	}
+	return nil
+}
+
+func (m *memoryStore) Upsert(points []VectorPoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ops := make([]memoryOp, len(points))
+	for i, p := range points {
+		for name, v := range p.Vectors {
+			if size, ok := m.vectors[name]; !ok || len(v) != size {
+				return fmt.Errorf("point %d: vector %q of size %d does not match the store", p.ID, name, len(v))
+			}
+		}
+		payload, _ := jsonValue(p.Payload).(map[string]interface{})
+		ops[i] = memoryOp{Upsert: &VectorPoint{ID: p.ID, Vectors: p.Vectors, Payload: payload}}
+	}
+	return m.write(ops)
+}
+
+func (m *memoryStore) Search(queries []VectorQuery) ([][]ScoredPoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := make([][]ScoredPoint, len(queries))
+	for i, q := range queries {
+		filter := normalizeFilter(q.Filter)
+		var hits []ScoredPoint
+		for _, p := range m.points {
+			v, ok := p.Vectors[q.Vector]
+			if !ok || !filter.matches(p.Payload) {
+				continue
+			}
+			if score := cosine(q.Values, v); score >= q.MinScore {
+				hits = append(hits, ScoredPoint{ID: p.ID, Score: score, Payload: p.Payload})
+			}
+		}
+		sort.Slice(hits, func(a, b int) bool {
+			if hits[a].Score != hits[b].Score {
+				return hits[a].Score > hits[b].Score
+			}
+			return hits[a].ID < hits[b].ID
+		})
+		if len(hits) > q.Limit {
+			hits = hits[:q.Limit]
+		}
+		results[i] = hits
+	}
+	return results, nil
+}
+
+func (m *memoryStore) Delete(filter VectorFilter) error {
+	filter = normalizeFilter(filter)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []int64
+	for id, p := range m.points {
+		if filter.matches(p.Payload) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return m.write([]memoryOp{{Delete: ids}})
+}
+
+func (m *memoryStore) Scroll(filter VectorFilter, fn func(VectorPoint) error) error {
+	filter = normalizeFilter(filter)
+
+	m.mu.RLock()
+	ids := make([]int64, 0, len(m.points))
+	for id, p := range m.points {
+		if filter.matches(p.Payload) {
+			ids = append(ids, id)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		m.mu.RLock()
+		p, ok := m.points[id]
+		m.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeFilter приводит значения фильтра к JSON-виду, в котором хранятся payload
+func normalizeFilter(f VectorFilter) VectorFilter {
+	norm := func(fields map[string]interface{}) map[string]interface{} {
+		out := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			out[k] = jsonValue(v)
+		}
+		return out
+	}
+	return VectorFilter{Must: norm(f.Must), MustNot: norm(f.MustNot)}
+}
+
+func (f VectorFilter) matches(payload map[string]interface{}) bool {
+	for k, v := range f.Must {
+		if !reflect.DeepEqual(payload[k], v) {
+			return false
+		}
+	}
+	for k, v := range f.MustNot {
+		if reflect.DeepEqual(payload[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
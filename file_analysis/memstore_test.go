@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testVectors = map[string]int{"model": 2, "hashing": 3}
+
+// testPoint — точка с вектором модели v и payload фрагмента сдачи
+func testPoint(id int64, v []float32, submission int, sender, work string) VectorPoint {
+	return VectorPoint{
+		ID:      id,
+		Vectors: map[string][]float32{"model": v},
+		Payload: map[string]interface{}{
+			"kind": "chunk", "submission_id": submission, "sender": sender, "work_id": work,
+		},
+	}
+}
+
+func newTestMemoryStore(t *testing.T, path string) *memoryStore {
+	t.Helper()
+	m, err := openMemoryStore(path)
+	if err != nil {
+		t.Fatalf("openMemoryStore: %v", err)
+	}
+	if err := m.Init(testVectors); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return m
+}
+
+func seedMemoryStore(t *testing.T, m *memoryStore) {
+	t.Helper()
+	err := m.Upsert([]VectorPoint{
+		testPoint(1, []float32{1, 0}, 10, "alice", "w1"),
+		testPoint(2, []float32{1, 0.1}, 11, "bob", "w1"),
+		testPoint(3, []float32{0.5, 1}, 12, "carol", "w1"),
+		testPoint(4, []float32{1, 0}, 13, "bob", "w2"),
+		{ID: 5, Vectors: map[string][]float32{"hashing": {1, 0, 0}}, Payload: map[string]interface{}{"kind": "chunk", "work_id": "w1"}},
+	})
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+}
+
+func storedIDs(t *testing.T, m *memoryStore) []int64 {
+	t.Helper()
+	var ids []int64
+	if err := m.Scroll(VectorFilter{}, func(p VectorPoint) error {
+		ids = append(ids, p.ID)
+		return nil
+	}); err != nil {
+		t.Fatalf("Scroll: %v", err)
+	}
+	return ids
+}
+
+func TestMemoryStoreSearch(t *testing.T) {
+	m := newTestMemoryStore(t, "")
+	seedMemoryStore(t, m)
+
+	tests := []struct {
+		name  string
+		query VectorQuery
+		want  []int64
+	}{
+		{"no filter", VectorQuery{Vector: "model", Values: []float32{1, 0}, Limit: 10}, []int64{1, 4, 2, 3}},
+		{"limit", VectorQuery{Vector: "model", Values: []float32{1, 0}, Limit: 2}, []int64{1, 4}},
+		{"min score", VectorQuery{Vector: "model", Values: []float32{1, 0}, Limit: 10, MinScore: 0.9}, []int64{1, 4, 2}},
+		{"must work", VectorQuery{Vector: "model", Values: []float32{1, 0}, Limit: 10,
+			Filter: VectorFilter{Must: map[string]interface{}{"work_id": "w1"}}}, []int64{1, 2, 3}},
+		{"must not sender", VectorQuery{Vector: "model", Values: []float32{1, 0}, Limit: 10,
+			Filter: VectorFilter{Must: map[string]interface{}{"work_id": "w1"}, MustNot: map[string]interface{}{"sender": "bob"}}}, []int64{1, 3}},
+		{"numeric payload", VectorQuery{Vector: "model", Values: []float32{1, 0}, Limit: 10,
+			Filter: VectorFilter{Must: map[string]interface{}{"submission_id": int64(12)}}}, []int64{3}},
+		{"missing field", VectorQuery{Vector: "model", Values: []float32{1, 0}, Limit: 10,
+			Filter: VectorFilter{Must: map[string]interface{}{"language": "ru"}}}, nil},
+		{"other vector", VectorQuery{Vector: "hashing", Values: []float32{1, 0, 0}, Limit: 10}, []int64{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := m.Search([]VectorQuery{tt.query})
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			var got []int64
+			for _, hit := range results[0] {
+				got = append(got, hit.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter VectorFilter
+		want   []int64
+	}{
+		{"by submission", VectorFilter{Must: map[string]interface{}{"submission_id": 11}}, []int64{1, 3, 4, 5}},
+		{"by work and sender", VectorFilter{Must: map[string]interface{}{"work_id": "w1", "sender": "bob"}}, []int64{1, 3, 4, 5}},
+		{"must not", VectorFilter{Must: map[string]interface{}{"kind": "chunk"}, MustNot: map[string]interface{}{"work_id": "w1"}}, []int64{1, 2, 3, 5}},
+		{"no match", VectorFilter{Must: map[string]interface{}{"work_id": "w3"}}, []int64{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMemoryStore(t, "")
+			seedMemoryStore(t, m)
+			if err := m.Delete(tt.filter); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if got := storedIDs(t, m); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreUpsertRejectsSize(t *testing.T) {
+	m := newTestMemoryStore(t, "")
+	err := m.Upsert([]VectorPoint{testPoint(1, []float32{1, 0, 0}, 10, "alice", "w1")})
+	if err == nil {
+		t.Fatal("Upsert accepted a vector of the wrong size")
+	}
+}
+
+func journalLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	defer f.Close()
+	n := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		n++
+	}
+	return n
+}
+
+func TestMemoryStoreJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.jsonl")
+
+	m := newTestMemoryStore(t, path)
+	seedMemoryStore(t, m)
+	if err := m.Delete(VectorFilter{Must: map[string]interface{}{"sender": "carol"}}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := m.Upsert([]VectorPoint{testPoint(2, []float32{0, 1}, 11, "bob", "w1")}); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	m.file.Close()
+
+	// Журнал проигрывается: удаление и перезапись точки сохраняются
+	replayed := newTestMemoryStore(t, path)
+	if got, want := storedIDs(t, replayed), []int64{1, 2, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("replayed points %v, want %v", got, want)
+	}
+	if got := replayed.points[2].Vectors["model"]; !reflect.DeepEqual(got, []float32{0, 1}) {
+		t.Errorf("point 2 has vector %v after replay, want the overwritten one", got)
+	}
+	if got := replayed.points[1].Payload["submission_id"]; got != float64(10) {
+		t.Errorf("point 1 payload submission_id = %#v, want 10 as JSON number", got)
+	}
+
+	// Устаревших записей больше, чем точек, — журнал переписывается только актуальными точками
+	for i := 0; i < 5; i++ {
+		if err := replayed.Upsert([]VectorPoint{testPoint(1, []float32{1, float32(i)}, 10, "alice", "w1")}); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+	}
+	if replayed.stale > len(replayed.points) {
+		t.Errorf("stale = %d for %d points, want the journal compacted", replayed.stale, len(replayed.points))
+	}
+	// В журнал записано 12 операций; после сжатия в нём точки и перезаписи после сжатия
+	if got, want := journalLines(t, path), len(replayed.points)+replayed.stale; got != want {
+		t.Errorf("journal has %d lines, want %d", got, want)
+	}
+	replayed.file.Close()
+
+	compacted := newTestMemoryStore(t, path)
+	defer compacted.file.Close()
+	if got, want := storedIDs(t, compacted), []int64{1, 2, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("points after compaction %v, want %v", got, want)
+	}
+	if got := compacted.points[1].Vectors["model"]; !reflect.DeepEqual(got, []float32{1, 4}) {
+		t.Errorf("point 1 has vector %v after compaction, want the last upsert", got)
+	}
+}
+
+func TestMemoryStoreInitRejectsResizedJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.jsonl")
+	m := newTestMemoryStore(t, path)
+	seedMemoryStore(t, m)
+	m.file.Close()
+
+	reopened, err := openMemoryStore(path)
+	if err != nil {
+		t.Fatalf("openMemoryStore: %v", err)
+	}
+	defer reopened.file.Close()
+	if err := reopened.Init(map[string]int{"model": 4, "hashing": 3}); err == nil {
+		t.Fatal("Init accepted points of another dimension")
+	}
+
+	// После Reset хранилище пустое и принимает новую размерность
+	if err := reopened.Reset(map[string]int{"model": 4}); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if ids := storedIDs(t, reopened); len(ids) != 0 {
+		t.Errorf("points after Reset: %v", ids)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	minhashSize    = 128
+	minhashBands   = 32
+	minhashRows    = minhashSize / minhashBands
+	minhashShingle = 3
+)
+
+// Область поиска почти-дубликатов (MINHASH_SCOPE)
+const (
+	minhashScopeWork   = "work"   // сдачи того же задания
+	minhashScopeCorpus = "corpus" // все сдачи, включая другие задания и прошлые годы
+)
+
+// Фиксированные seed'ы: сигнатуры хранятся на диске и должны совпадать между перезапусками
+var minhashSeeds = func() [minhashSize]uint64 {
+	var seeds [minhashSize]uint64
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range seeds {
+		x = splitmix64(x)
+		seeds[i] = x
+	}
+	return seeds
+}()
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+// minhashSignature строит MinHash-сигнатуру множества шинглов из minhashShingle слов
+func minhashSignature(tokens []Token) []uint32 {
+	n := minhashShingle
+	if len(tokens) < n {
+		n = len(tokens)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	sig := make([]uint32, minhashSize)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+
+	for i := 0; i+n <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+n] {
+			h.Write([]byte(t.Text))
+			h.Write([]byte{0})
+		}
+		shingle := h.Sum64()
+		for j, seed := range minhashSeeds {
+			if v := uint32(splitmix64(shingle ^ seed)); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+	return sig
+}
+
+// estimateJaccard — доля совпавших позиций сигнатур
+func estimateJaccard(a, b []uint32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+type minhashEntry struct {
+	ID        string   `json:"id"`
+	Sender    string   `json:"sender"`
+	WorkID    string   `json:"work_id"`
+	FileName  string   `json:"file_name"`
+	Timestamp string   `json:"timestamp"`
+	Signature []uint32 `json:"signature"`
+}
+
+// MinHashIndex — LSH-индекс по полосам сигнатур (minhashBands полос по minhashRows строк).
+// Записи дописываются в JSON Lines файл; при открытии индекс восстанавливается из него,
+// последняя запись с тем же ID побеждает.
+type MinHashIndex struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	entries map[string]minhashEntry
+	buckets map[uint64][]string
+	stale   int
+}
+
+func openMinHashIndex(path string) (*MinHashIndex, error) {
+	idx := &MinHashIndex{
+		path:    path,
+		entries: make(map[string]minhashEntry),
+		buckets: make(map[uint64][]string),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			var entry minhashEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				log.Printf("Skipping corrupt MinHash index line: %v", err)
+				continue
+			}
+			idx.insert(entry)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read MinHash index: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open MinHash index: %w", err)
+	}
+
+	if idx.stale > len(idx.entries) {
+		if err := idx.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MinHash index for writing: %w", err)
+	}
+	idx.file = f
+
+	log.Printf("MinHash index loaded: %d documents", len(idx.entries))
+	return idx, nil
+}
+
+func bandKey(band int, sig []uint32) uint64 {
+	var buf [4]byte
+	h := fnv.New64a()
+	binary.BigEndian.PutUint32(buf[:], uint32(band))
+	h.Write(buf[:])
+	for _, v := range sig[band*minhashRows : (band+1)*minhashRows] {
+		binary.BigEndian.PutUint32(buf[:], v)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// insert добавляет запись в память; вызывается под idx.mu
+func (idx *MinHashIndex) insert(entry minhashEntry) {
+	if len(entry.Signature) != minhashSize {
+		return
+	}
+	if _, ok := idx.entries[entry.ID]; ok {
+		idx.remove(entry.ID)
+		idx.stale++
+	}
+	idx.entries[entry.ID] = entry
+	for band := 0; band < minhashBands; band++ {
+		key := bandKey(band, entry.Signature)
+		idx.buckets[key] = append(idx.buckets[key], entry.ID)
+	}
+}
+
+func (idx *MinHashIndex) remove(id string) {
+	entry := idx.entries[id]
+	for band := 0; band < minhashBands; band++ {
+		key := bandKey(band, entry.Signature)
+		ids := idx.buckets[key]
+		for i, other := range ids {
+			if other == id {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(idx.buckets, key)
+		} else {
+			idx.buckets[key] = ids
+		}
+	}
+	delete(idx.entries, id)
+}
+
+func (idx *MinHashIndex) Add(entry minhashEntry) error {
+	if len(entry.Signature) != minhashSize {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal MinHash entry: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, err := idx.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append to MinHash index: %w", err)
+	}
+	idx.insert(entry)
+
+	if idx.stale > len(idx.entries) {
+		if err := idx.compact(); err != nil {
+			log.Printf("Error compacting MinHash index: %v", err)
+		}
+	}
+	return nil
+}
+
+// compact переписывает файл индекса только актуальными записями; вызывается под idx.mu
+func (idx *MinHashIndex) compact() error {
+	tmp := idx.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted index: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	for _, entry := range idx.entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write compacted index: %w", err)
+	}
+	f.Close()
+
+	if err := os.Rename(tmp, idx.path); err != nil {
+		return fmt.Errorf("failed to replace index: %w", err)
+	}
+
+	if idx.file != nil {
+		idx.file.Close()
+		idx.file, err = os.OpenFile(idx.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to reopen index: %w", err)
+		}
+	}
+	idx.stale = 0
+	return nil
+}
+
+type minhashCandidate struct {
+	Entry   minhashEntry
+	Jaccard float64
+}
+
+// Query возвращает документы, попавшие в общую LSH-корзину хотя бы по одной полосе,
+// с оценкой Jaccard не ниже threshold. Учитываются только сдачи задания workID, а при corpus —
+// сдачи всех заданий; свои работы отправителя исключаются.
+func (idx *MinHashIndex) Query(sig []uint32, workID string, corpus bool, excludeSender string, threshold float64, limit int) []minhashCandidate {
+	if len(sig) != minhashSize {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var candidates []minhashCandidate
+	for band := 0; band < minhashBands; band++ {
+		for _, id := range idx.buckets[bandKey(band, sig)] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			entry := idx.entries[id]
+			if (!corpus && entry.WorkID != workID) || entry.Sender == excludeSender {
+				continue
+			}
+			if j := estimateJaccard(sig, entry.Signature); j >= threshold {
+				candidates = append(candidates, minhashCandidate{Entry: entry, Jaccard: j})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Jaccard > candidates[j].Jaccard })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const minhashTestText = `Метод конечных элементов разбивает область на простые части и сводит
+дифференциальное уравнение к системе линейных уравнений для значений в узлах сетки`
+
+func TestMinHashIndexQueryScope(t *testing.T) {
+	idx, err := openMinHashIndex(filepath.Join(t.TempDir(), "index.jsonl"))
+	if err != nil {
+		t.Fatalf("openMinHashIndex: %v", err)
+	}
+	defer idx.file.Close()
+
+	sig := minhashSignature(tokenize(minhashTestText))
+	other := minhashSignature(tokenize("Совсем другой текст о истории древнего Рима и его императорах"))
+	for _, e := range []minhashEntry{
+		{ID: "same-work", Sender: "bob", WorkID: "w1", Signature: sig},
+		{ID: "other-work", Sender: "carol", WorkID: "w2", Signature: sig},
+		{ID: "own", Sender: "alice", WorkID: "w2", Signature: sig},
+		{ID: "unrelated", Sender: "dave", WorkID: "w1", Signature: other},
+	} {
+		if err := idx.Add(e); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		corpus bool
+		want   []string
+	}{
+		{"work", false, []string{"same-work"}},
+		{"corpus", true, []string{"other-work", "same-work"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range idx.Query(sig, "w1", tt.corpus, "alice", 0.5, 10) {
+				got = append(got, c.Entry.ID)
+				if c.Jaccard != 1 {
+					t.Errorf("%s: jaccard %v, want 1", c.Entry.ID, c.Jaccard)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
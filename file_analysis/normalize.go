@@ -0,0 +1,180 @@
+package main
+
+import (
+	"unicode"
+)
+
+// Obfuscation — следы попыток обмануть проверку: буквы чужого алфавита, подменяющие похожие
+// (латинская «a» вместо кириллической «а»), и невидимые символы внутри слов
+type Obfuscation struct {
+	Detected      bool `json:"detected"`
+	Homoglyphs    int  `json:"homoglyphs"`    // заменённые буквы-двойники
+	Invisible     int  `json:"invisible"`     // удалённые символы нулевой ширины и управления направлением
+	Compatibility int  `json:"compatibility"` // совместимые формы: полноширинные знаки, лигатуры, особые пробелы
+}
+
+// Невидимые символы, которые удаляются из текста
+var invisibleRunes = map[rune]bool{
+	0x200B: true, 0x200C: true, 0x200D: true, 0x200E: true, 0x200F: true, 0x2060: true, 0x2061: true,
+	0x2062: true, 0x2063: true, 0x2064: true, 0xFEFF: true, 0x180E: true, 0x034F: true,
+	0x202A: true, 0x202B: true, 0x202C: true, 0x202D: true, 0x202E: true,
+	0x2066: true, 0x2067: true, 0x2068: true, 0x2069: true,
+}
+
+// Совместимые формы Unicode (подмножество NFKC, встречающееся в текстах студентов)
+var compatibilityForms = map[rune]string{
+	0x00AD: "", // мягкий перенос из Word не считается обфускацией, но удаляется
+	0x00A0: " ", 0x2000: " ", 0x2001: " ", 0x2002: " ", 0x2003: " ", 0x2004: " ", 0x2005: " ",
+	0x2006: " ", 0x2007: " ", 0x2008: " ", 0x2009: " ", 0x200A: " ", 0x202F: " ", 0x205F: " ", 0x3000: " ",
+	0xFB00: "ff", 0xFB01: "fi", 0xFB02: "fl", 0xFB03: "ffi", 0xFB04: "ffl", 0xFB05: "st", 0xFB06: "st",
+	0x2024: ".", 0x2025: "..", 0x2026: "...", 0x2116: "No",
+}
+
+// Сочетания с комбинируемыми знаками, которые в NFC становятся одной буквой
+var compositions = map[[2]rune]rune{
+	{'и', 0x0306}: 'й', {'И', 0x0306}: 'Й', {'е', 0x0308}: 'ё', {'Е', 0x0308}: 'Ё',
+	{'у', 0x0306}: 'ў', {'У', 0x0306}: 'Ў', {'і', 0x0308}: 'ї', {'І', 0x0308}: 'Ї',
+}
+
+// Буквы-двойники: кириллица и соответствующая ей латиница; греческие буквы сводятся к обеим
+var (
+	latinToCyrillic = map[rune]rune{
+		'a': 'а', 'c': 'с', 'e': 'е', 'o': 'о', 'p': 'р', 'x': 'х', 'y': 'у',
+		'A': 'А', 'B': 'В', 'C': 'С', 'E': 'Е', 'H': 'Н', 'K': 'К', 'M': 'М', 'O': 'О', 'P': 'Р',
+		'T': 'Т', 'X': 'Х',
+	}
+	cyrillicToLatin = make(map[rune]rune)
+	greekHomoglyphs = map[rune]rune{ // греческая буква → латинский двойник
+		'α': 'a', 'ο': 'o', 'ρ': 'p', 'ε': 'e', 'χ': 'x', 'υ': 'y',
+		'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Η': 'H', 'Κ': 'K', 'Μ': 'M', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Χ': 'X',
+	}
+)
+
+func init() {
+	for l, c := range latinToCyrillic {
+		cyrillicToLatin[c] = l
+	}
+}
+
+// normalizeDocument приводит текст и код документа к каноническому виду до всех анализаторов:
+// совместимые формы заменяются обычными, невидимые символы удаляются, а буквы-двойники в словах
+// со смешанным алфавитом заменяются буквами алфавита слова. Смещения блоков пересчитываются.
+func normalizeDocument(doc *Document) Obfuscation {
+	var o Obfuscation
+	text, positions := normalizeText(doc.Text, &o)
+	for i := range doc.Blocks {
+		doc.Blocks[i].Start = positions[min(doc.Blocks[i].Start, len(positions)-1)]
+		doc.Blocks[i].End = positions[min(doc.Blocks[i].End, len(positions)-1)]
+	}
+	doc.Text = text
+	// Переводы строк не удаляются, поэтому номера строк кода остаются прежними
+	doc.Code, _ = normalizeText(doc.Code, &o)
+
+	o.Detected = o.Homoglyphs+o.Invisible >= config.ObfuscationMinChars
+	return o
+}
+
+// normalizeText возвращает нормализованный текст и для каждого смещения исходного текста
+// (в символах, включая конец) соответствующее смещение в новом
+func normalizeText(text string, o *Obfuscation) (string, []int) {
+	src := []rune(text)
+	out := make([]rune, 0, len(src))
+	positions := make([]int, len(src)+1)
+
+	for i, r := range src {
+		positions[i] = len(out)
+		switch {
+		case invisibleRunes[r]:
+			o.Invisible++
+		case r >= 0xFF01 && r <= 0xFF5E:
+			// Полноширинные ASCII
+			out = append(out, r-0xFF01+'!')
+			o.Compatibility++
+		case compatibilityForms[r] != "" || r == 0x00AD:
+			out = append(out, []rune(compatibilityForms[r])...)
+			o.Compatibility++
+		default:
+			if len(out) > 0 {
+				if c, ok := compositions[[2]rune{out[len(out)-1], r}]; ok {
+					out[len(out)-1] = c
+					continue
+				}
+			}
+			out = append(out, r)
+		}
+	}
+	positions[len(src)] = len(out)
+
+	o.Homoglyphs += replaceHomoglyphs(out)
+	return string(out), positions
+}
+
+// replaceHomoglyphs исправляет слова, в которых смешаны кириллица и латиница. Алфавит слова
+// определяют буквы, у которых нет двойника; если таких нет — большинство букв (при равенстве
+// кириллица). Возвращает число заменённых букв.
+func replaceHomoglyphs(rs []rune) int {
+	replaced := 0
+	for start := 0; start < len(rs); {
+		if !unicode.IsLetter(rs[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(rs) && (unicode.IsLetter(rs[end]) || unicode.Is(unicode.Mn, rs[end])) {
+			end++
+		}
+		replaced += fixWord(rs[start:end])
+		start = end
+	}
+	return replaced
+}
+
+func fixWord(word []rune) int {
+	var cyr, lat, greek, cyrStrict, latStrict int
+	for _, r := range word {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyr++
+			if _, ok := cyrillicToLatin[r]; !ok {
+				cyrStrict++
+			}
+		case unicode.Is(unicode.Latin, r):
+			lat++
+			if _, ok := latinToCyrillic[r]; !ok {
+				latStrict++
+			}
+		case greekHomoglyphs[r] != 0:
+			greek++
+		case unicode.Is(unicode.Greek, r):
+			return 0 // греческое слово
+		}
+	}
+
+	switch {
+	case greek == 0 && (cyr == 0 || lat == 0), cyr+lat == 0:
+		return 0
+	case cyrStrict > 0 && latStrict > 0:
+		// У обеих частей есть буквы без двойников («pythonовский»): слово смешанное, а не подменённое
+		return 0
+	}
+	toCyrillic := cyrStrict > 0 || latStrict == 0 && cyr >= lat
+
+	replaced := 0
+	for i, r := range word {
+		if g, ok := greekHomoglyphs[r]; ok {
+			r = g
+		}
+		if toCyrillic {
+			if c, ok := latinToCyrillic[r]; ok {
+				r = c
+			}
+		} else if l, ok := cyrillicToLatin[r]; ok {
+			r = l
+		}
+		if r != word[i] {
+			word[i] = r
+			replaced++
+		}
+	}
+	return replaced
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Языки ядер Jupyter, для которых есть токенизатор кода
+var notebookLanguages = map[string]string{
+	"python": "python", "python3": "python", "java": "java", "go": "go",
+	"c": "c", "c++": "cpp", "cpp": "cpp", "c++11": "cpp", "c++14": "cpp", "c++17": "cpp",
+}
+
+type notebookCell struct {
+	CellType string          `json:"cell_type"`
+	Source   json.RawMessage `json:"source"`
+	Input    json.RawMessage `json:"input"` // код ячейки в nbformat 3
+}
+
+type notebookFile struct {
+	NBFormat int            `json:"nbformat"`
+	Cells    []notebookCell `json:"cells"`
+	// В nbformat 3 ячейки лежат в листах
+	Worksheets []struct {
+		Cells []notebookCell `json:"cells"`
+	} `json:"worksheets"`
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		Language string `json:"language"`
+	} `json:"metadata"`
+}
+
+// cellSource склеивает исходник ячейки: строка или массив строк
+func cellSource(raw json.RawMessage) string {
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		return strings.Join(lines, "")
+	}
+	var s string
+	json.Unmarshal(raw, &s)
+	return s
+}
+
+// notebookLanguage определяет язык кода блокнота по метаданным; по умолчанию Python
+func (nb *notebookFile) language() string {
+	for _, name := range []string{nb.Metadata.LanguageInfo.Name, nb.Metadata.Kernelspec.Language, nb.Metadata.Language} {
+		if name != "" {
+			return notebookLanguages[strings.ToLower(name)]
+		}
+	}
+	return "python"
+}
+
+// extractNotebook разбирает блокнот Jupyter: markdown-ячейки становятся текстом документа,
+// ячейки кода склеиваются в отдельный исходник, выводы ячеек отбрасываются. Блоки текста
+// и кода помнят номер ячейки (с единицы).
+func extractNotebook(data []byte) (Document, error) {
+	var nb notebookFile
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return Document{}, fmt.Errorf("invalid notebook: %w", err)
+	}
+	cells := nb.Cells
+	for _, ws := range nb.Worksheets {
+		cells = append(cells, ws.Cells...)
+	}
+	if nb.NBFormat == 0 || len(cells) == 0 {
+		return Document{}, fmt.Errorf("notebook has no cells: %w", errUnsupportedFormat)
+	}
+
+	var prose sectionBuilder
+	var code strings.Builder
+	var codeBlocks []Block
+	line := 1
+
+	for i, cell := range cells {
+		switch cell.CellType {
+		case "markdown":
+			prose.cell = i + 1
+			prose.addMarkdown(cellSource(cell.Source))
+
+		case "code":
+			src := cellSource(cell.Source)
+			if src == "" {
+				src = cellSource(cell.Input)
+			}
+			lines := strings.Split(strings.TrimRight(src, "\n"), "\n")
+			for j, l := range lines {
+				// Магические команды IPython (%timeit, !pip) — не код на языке блокнота
+				if t := strings.TrimSpace(l); strings.HasPrefix(t, "%") || strings.HasPrefix(t, "!") {
+					lines[j] = ""
+				}
+			}
+			if strings.TrimSpace(strings.Join(lines, "")) == "" {
+				continue
+			}
+			codeBlocks = append(codeBlocks, Block{Start: line, End: line + len(lines), Cell: i + 1})
+			code.WriteString(strings.Join(lines, "\n"))
+			code.WriteByte('\n')
+			line += len(lines)
+		}
+	}
+
+	doc := prose.document("ipynb")
+	doc.Code = code.String()
+	doc.CodeBlocks = codeBlocks
+	if doc.Code != "" {
+		doc.CodeLanguage = nb.language()
+	}
+	return doc, nil
+}
+
+// locateCodeRegions дополняет совпавшие участки кода номерами ячеек блокнотов
+func locateCodeRegions(report *Report, blocks []Block) {
+	cell := func(blocks []Block, line int) int {
+		if loc := locate(blocks, line); loc != nil {
+			return loc.Cell
+		}
+		return 0
+	}
+
+	for i := range report.CodeMatches {
+		m := &report.CodeMatches[i]
+		var other []Block
+		if sub, err := loadSubmission(m.ID); err == nil {
+			other = sub.CodeBlocks
+		}
+		for j := range m.Regions {
+			r := &m.Regions[j]
+			r.Cell = cell(blocks, r.StartLine)
+			r.OtherCell = cell(other, r.OtherStartLine)
+		}
+	}
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Минимальный разбор PDF без внешних зависимостей: объекты ищутся сканированием файла
+// (таблица xref не нужна и часто бывает битой), поддерживаются потоки объектов,
+// фильтры FlateDecode, ASCIIHexDecode и ASCII85Decode.
+
+var (
+	errEncryptedPDF = errors.New("encrypted PDF is not supported")
+	errNoPDFText    = errors.New("PDF has no extractable text")
+)
+
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfDict    map[string]interface{}
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		data []byte // данные как в файле, до применения фильтров
+	}
+)
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// object читает следующий объект; операторы потока содержимого и служебные слова
+// возвращаются как pdfKeyword, ссылки "n g R" — как pdfRef
+func (l *pdfLexer) object() (interface{}, error) {
+	return l.objectDepth(0)
+}
+
+func (l *pdfLexer) objectDepth(depth int) (interface{}, error) {
+	if depth > 64 {
+		return nil, fmt.Errorf("PDF object nesting is too deep")
+	}
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		return l.name(), nil
+
+	case c == '(':
+		return l.literalString(), nil
+
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		dict := make(pdfDict)
+		for {
+			l.skipSpace()
+			if l.pos+1 < len(l.data) && l.data[l.pos] == '>' && l.data[l.pos+1] == '>' {
+				l.pos += 2
+				return dict, nil
+			}
+			key, err := l.objectDepth(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				continue
+			}
+			value, err := l.objectDepth(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[string(name)] = value
+		}
+
+	case c == '<':
+		return l.hexString(), nil
+
+	case c == '[':
+		l.pos++
+		var arr []interface{}
+		for {
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == ']' {
+				l.pos++
+				return arr, nil
+			}
+			v, err := l.objectDepth(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(c), nil
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+
+	if n, err := strconv.Atoi(word); err == nil {
+		// Ссылка на объект: "12 0 R"
+		save := l.pos
+		l.skipSpace()
+		gstart := l.pos
+		for l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+			l.pos++
+		}
+		if l.pos > gstart {
+			gen, _ := strconv.Atoi(string(l.data[gstart:l.pos]))
+			l.skipSpace()
+			if l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+				(l.pos+1 == len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelimiter(l.data[l.pos+1])) {
+				l.pos++
+				return pdfRef{n, gen}, nil
+			}
+		}
+		l.pos = save
+		return float64(n), nil
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, nil
+	}
+
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		l.pos++
+		return pdfKeyword(c), nil
+	}
+	return pdfKeyword(word), nil
+}
+
+func (l *pdfLexer) name() pdfName {
+	l.pos++
+	var b []byte
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		l.pos++
+	}
+	return pdfName(b)
+}
+
+func (l *pdfLexer) literalString() []byte {
+	l.pos++
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return b
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+func (l *pdfLexer) hexString() []byte {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b, _ := hex.DecodeString(string(digits))
+	return b
+}
+
+type pdfFile struct {
+	objects map[int]interface{}
+	trailer pdfDict
+}
+
+var (
+	pdfObjectPattern  = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailerPattern = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// parsePDF находит все объекты файла; при повторных определениях (инкрементальные
+// обновления) побеждает последнее
+func parsePDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	f := &pdfFile{objects: make(map[int]interface{}), trailer: make(pdfDict)}
+	end := 0
+	for _, m := range pdfObjectPattern.FindAllSubmatchIndex(data, -1) {
+		if m[0] < end {
+			continue // совпадение внутри данных предыдущего потока
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		obj, err := l.object()
+		if err != nil {
+			continue
+		}
+
+		if dict, ok := obj.(pdfDict); ok {
+			save := l.pos
+			if kw, _ := l.object(); kw == pdfKeyword("stream") {
+				obj = f.readStream(l, dict)
+			} else {
+				l.pos = save
+			}
+		}
+		f.objects[num] = obj
+		end = l.pos
+
+		if s, ok := obj.(*pdfStream); ok && s.dict["Type"] == pdfName("XRef") {
+			for k, v := range s.dict {
+				f.trailer[k] = v
+			}
+		}
+	}
+
+	for _, idx := range pdfTrailerPattern.FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: idx[0] + len("trailer")}
+		if dict, ok := mustObject(l).(pdfDict); ok {
+			for k, v := range dict {
+				f.trailer[k] = v
+			}
+		}
+	}
+	if _, ok := f.trailer["Encrypt"]; ok {
+		return nil, errEncryptedPDF
+	}
+
+	f.expandObjectStreams()
+	return f, nil
+}
+
+func mustObject(l *pdfLexer) interface{} {
+	obj, _ := l.object()
+	return obj
+}
+
+func (f *pdfFile) readStream(l *pdfLexer, dict pdfDict) *pdfStream {
+	data := l.data
+	pos := l.pos
+	if pos < len(data) && data[pos] == '\r' {
+		pos++
+	}
+	if pos < len(data) && data[pos] == '\n' {
+		pos++
+	}
+
+	// /Length может быть ссылкой на ещё не прочитанный объект, поэтому ему доверяем,
+	// только если за данными действительно следует endstream
+	if n, ok := dict["Length"].(float64); ok && n >= 0 && pos+int(n) <= len(data) {
+		rest := bytes.TrimLeft(data[pos+int(n):min(pos+int(n)+16, len(data))], "\r\n \t")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			l.pos = pos + int(n)
+			return &pdfStream{dict: dict, data: data[pos : pos+int(n)]}
+		}
+	}
+
+	endIdx := bytes.Index(data[pos:], []byte("endstream"))
+	if endIdx < 0 {
+		l.pos = len(data)
+		return &pdfStream{dict: dict, data: data[pos:]}
+	}
+	l.pos = pos + endIdx + len("endstream")
+	return &pdfStream{dict: dict, data: bytes.TrimRight(data[pos:pos+endIdx], "\r\n")}
+}
+
+// expandObjectStreams достаёт объекты, упакованные в потоки /Type /ObjStm (PDF 1.5+)
+func (f *pdfFile) expandObjectStreams() {
+	for _, obj := range f.objects {
+		s, ok := obj.(*pdfStream)
+		if !ok || s.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := f.decodeStream(s)
+		if err != nil {
+			continue
+		}
+		n, _ := f.resolve(s.dict["N"]).(float64)
+		first, _ := f.resolve(s.dict["First"]).(float64)
+		// Заголовок — пары «номер смещение», на пару нужно не меньше 4 байт
+		if n < 0 || n > float64(len(data)/4) || first < 0 || first >= float64(len(data)) {
+			continue
+		}
+
+		header := &pdfLexer{data: data}
+		for i := 0; i < int(n); i++ {
+			num, ok1 := mustObject(header).(float64)
+			offset, ok2 := mustObject(header).(float64)
+			if !ok1 || !ok2 || num < 0 || offset < 0 || first+offset >= float64(len(data)) {
+				break
+			}
+			if _, exists := f.objects[int(num)]; exists {
+				continue
+			}
+			l := &pdfLexer{data: data, pos: int(first) + int(offset)}
+			if v, err := l.object(); err == nil {
+				f.objects[int(num)] = v
+			}
+		}
+	}
+}
+
+// resolve разыменовывает ссылки на объекты
+func (f *pdfFile) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num]
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(v interface{}) pdfDict {
+	switch v := f.resolve(v).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (f *pdfFile) array(v interface{}) []interface{} {
+	arr, _ := f.resolve(v).([]interface{})
+	return arr
+}
+
+func (f *pdfFile) number(v interface{}) float64 {
+	n, _ := f.resolve(v).(float64)
+	return n
+}
+
+// decodeStream применяет фильтры потока
+func (f *pdfFile) decodeStream(s *pdfStream) ([]byte, error) {
+	var filters []interface{}
+	switch v := f.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []interface{}{v}
+	case []interface{}:
+		filters = v
+	}
+
+	data := s.data
+	for _, filter := range filters {
+		name, _ := f.resolve(filter).(pdfName)
+		var err error
+		switch name {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+		case "ASCIIHexDecode", "AHx":
+			l := &pdfLexer{data: append(append([]byte{'<'}, bytes.TrimSuffix(bytes.TrimSpace(data), []byte(">"))...), '>')}
+			data = l.hexString()
+		case "ASCII85Decode", "A85":
+			data, err = decodeASCII85(data)
+		default:
+			return nil, fmt.Errorf("unsupported PDF filter %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inflate PDF stream: %w", err)
+	}
+	defer r.Close()
+
+	// Обрезанный поток часто всё равно содержит полезный текст
+	out, err := ioutil.ReadAll(io.LimitReader(r, maxDocumentPart))
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("failed to inflate PDF stream: %w", err)
+	}
+	return out, nil
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	// «z» разворачивается в четыре нулевых байта, поэтому размер — на худший случай
+	out := make([]byte, 4*len(data)+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ASCII85 stream: %w", err)
+	}
+	return out[:n], nil
+}
+
+// pages возвращает словари страниц в порядке дерева страниц вместе с унаследованными ресурсами
+func (f *pdfFile) pages() []pdfPage {
+	var pages []pdfPage
+	visited := make(map[int]bool)
+
+	var walk func(node interface{}, resources pdfDict, mediaBox interface{}, depth int)
+	walk = func(node interface{}, resources pdfDict, mediaBox interface{}, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+		dict := f.dict(node)
+		if dict == nil || depth > 64 {
+			return
+		}
+		if r := f.dict(dict["Resources"]); r != nil {
+			resources = r
+		}
+		if box, ok := dict["MediaBox"]; ok {
+			mediaBox = box
+		}
+
+		kids := f.array(dict["Kids"])
+		if dict["Type"] == pdfName("Page") || (kids == nil && dict["Contents"] != nil) {
+			pages = append(pages, pdfPage{dict: dict, resources: resources, mediaBox: mediaBox})
+			return
+		}
+		for _, kid := range kids {
+			walk(kid, resources, mediaBox, depth+1)
+		}
+	}
+
+	if root := f.dict(f.trailer["Root"]); root != nil {
+		walk(root["Pages"], nil, nil, 0)
+	}
+	if len(pages) == 0 {
+		// Нет каталога — собираем страницы по номерам объектов
+		nums := make([]int, 0, len(f.objects))
+		for num := range f.objects {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		for _, num := range nums {
+			if d := f.dict(f.objects[num]); d != nil && d["Type"] == pdfName("Page") {
+				pages = append(pages, pdfPage{dict: d, resources: f.dict(d["Resources"]), mediaBox: d["MediaBox"]})
+			}
+		}
+	}
+	return pages
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+	mediaBox  interface{}
+}
+
+// contents — склеенные потоки содержимого страницы
+func (f *pdfFile) contents(page pdfPage) []byte {
+	var parts []interface{}
+	switch v := f.resolve(page.dict["Contents"]).(type) {
+	case []interface{}:
+		parts = v
+	case *pdfStream:
+		parts = []interface{}{v}
+	}
+
+	var buf bytes.Buffer
+	for _, part := range parts {
+		s, ok := f.resolve(part).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := f.decodeStream(s)
+		if err != nil {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
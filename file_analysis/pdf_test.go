@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// objStmPDF собирает PDF с одним потоком /ObjStm без фильтров
+func objStmPDF(n, first int, body string) []byte {
+	return []byte(fmt.Sprintf("%%PDF-1.5\n1 0 obj\n<< /Type /ObjStm /N %d /First %d /Length %d >>\nstream\n%s\nendstream\nendobj\n",
+		n, first, len(body), body))
+}
+
+func TestExpandObjectStreams(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		found bool
+	}{
+		{"valid", objStmPDF(1, 4, "7 0 (hi)"), true},
+		{"negative offset", objStmPDF(1, 8, "7 -20   (hi)"), false},
+		{"negative first", objStmPDF(1, -4, "7 0 (hi)"), false},
+		{"first past end", objStmPDF(1, 100, "7 0 (hi)"), false},
+		{"huge count", objStmPDF(1<<30, 4, "7 0 (hi)"), false},
+		{"truncated header", objStmPDF(2, 4, "7 0 (hi)"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parsePDF(tt.data)
+			if err != nil {
+				t.Fatalf("parsePDF: %v", err)
+			}
+			if _, ok := f.objects[7]; ok != tt.found {
+				t.Errorf("object 7 found = %v, want %v", ok, tt.found)
+			}
+		})
+	}
+}
+
+func TestDecodeASCII85(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []byte
+	}{
+		{"plain", "<~87cURD]i,\"Ebo80~>", []byte("Hello World!")},
+		{"zeros", "zzzz~>", make([]byte, 16)},
+		{"mixed", "87cURzD]i,\"Ebo80", append(append([]byte("Hell"), 0, 0, 0, 0), []byte("o World!")...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeASCII85([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("decodeASCII85: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
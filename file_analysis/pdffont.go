@@ -0,0 +1,298 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// pdfFont переводит коды символов строки PDF в Unicode и знает ширины глифов,
+// по которым восстанавливаются пробелы между словами
+type pdfFont struct {
+	codeBytes    int               // 1 — простой шрифт, 2 — составной (Type0)
+	toUnicode    map[uint32]string // из CMap /ToUnicode
+	encoding     [256]string       // для простых шрифтов без ToUnicode
+	widths       map[uint32]float64
+	defaultWidth float64
+}
+
+type pdfGlyph struct {
+	text  string
+	width float64 // в тысячных долях кегля
+	space bool    // однобайтовый код 32 — к нему применяется Tw
+}
+
+func (f *pdfFile) loadFont(v interface{}) *pdfFont {
+	dict := f.dict(v)
+	font := &pdfFont{codeBytes: 1, widths: make(map[uint32]float64), defaultWidth: 500}
+	if dict == nil {
+		font.encoding = standardEncoding(1252)
+		return font
+	}
+
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeBytes = 2
+		font.defaultWidth = 1000
+		if descendants := f.array(dict["DescendantFonts"]); len(descendants) > 0 {
+			if cid := f.dict(descendants[0]); cid != nil {
+				if dw := f.number(cid["DW"]); dw > 0 {
+					font.defaultWidth = dw
+				}
+				font.loadCIDWidths(f, f.array(cid["W"]))
+			}
+		}
+	} else {
+		font.encoding = f.simpleEncoding(dict["Encoding"])
+		first := int(f.number(dict["FirstChar"]))
+		for i, w := range f.array(dict["Widths"]) {
+			font.widths[uint32(first+i)] = f.number(w)
+		}
+	}
+
+	if s, ok := f.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := f.decodeStream(s); err == nil {
+			font.toUnicode, font.codeBytes = parseToUnicode(data, font.codeBytes)
+		}
+	}
+	return font
+}
+
+// loadCIDWidths разбирает массив /W: "c [w1 w2 ...]" или "c1 c2 w"
+func (font *pdfFont) loadCIDWidths(f *pdfFile, w []interface{}) {
+	for i := 0; i < len(w); {
+		first, ok := f.resolve(w[i]).(float64)
+		if !ok || i+1 >= len(w) {
+			return
+		}
+		if arr, ok := f.resolve(w[i+1]).([]interface{}); ok {
+			for j, width := range arr {
+				font.widths[uint32(first)+uint32(j)] = f.number(width)
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last := f.number(w[i+1])
+		width := f.number(w[i+2])
+		for c := first; c <= last && c-first < 65536; c++ {
+			font.widths[uint32(c)] = width
+		}
+		i += 3
+	}
+}
+
+func (font *pdfFont) decode(s []byte) []pdfGlyph {
+	glyphs := make([]pdfGlyph, 0, len(s)/font.codeBytes+1)
+	for i := 0; i+font.codeBytes <= len(s); i += font.codeBytes {
+		var code uint32
+		for _, b := range s[i : i+font.codeBytes] {
+			code = code<<8 | uint32(b)
+		}
+
+		g := pdfGlyph{space: font.codeBytes == 1 && code == 32}
+		if text, ok := font.toUnicode[code]; ok {
+			g.text = text
+		} else if font.codeBytes == 1 {
+			g.text = font.encoding[code]
+		}
+		if w, ok := font.widths[code]; ok && w > 0 {
+			g.width = w
+		} else {
+			g.width = font.defaultWidth
+		}
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
+
+func standardEncoding(codepage int) [256]string {
+	var enc [256]string
+	for c := 32; c < 256; c++ {
+		if text, _ := decodeCodepage([]byte{byte(c)}, codepage); text != "\uFFFD" {
+			enc[c] = text
+		}
+	}
+	return enc
+}
+
+// simpleEncoding — базовая кодировка простого шрифта с заменами из /Differences
+func (f *pdfFile) simpleEncoding(v interface{}) [256]string {
+	enc := standardEncoding(1252)
+	dict, ok := f.resolve(v).(pdfDict)
+	if !ok {
+		return enc
+	}
+
+	code := 0
+	for _, item := range f.array(dict["Differences"]) {
+		switch item := f.resolve(item).(type) {
+		case float64:
+			code = int(item)
+		case pdfName:
+			if code >= 0 && code < 256 {
+				if text, ok := glyphText(string(item)); ok {
+					enc[code] = text
+				}
+			}
+			code++
+		}
+	}
+	return enc
+}
+
+// Имена глифов Adobe Glyph List для знаков ASCII, которые не совпадают с самим символом
+var glyphNames = map[string]string{
+	"space": " ", "exclam": "!", "quotedbl": "\"", "numbersign": "#", "dollar": "$", "percent": "%",
+	"ampersand": "&", "quotesingle": "'", "quoteright": "’", "quoteleft": "‘", "parenleft": "(",
+	"parenright": ")", "asterisk": "*", "plus": "+", "comma": ",", "hyphen": "-", "period": ".",
+	"slash": "/", "zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5",
+	"six": "6", "seven": "7", "eight": "8", "nine": "9", "colon": ":", "semicolon": ";", "less": "<",
+	"equal": "=", "greater": ">", "question": "?", "at": "@", "bracketleft": "[", "backslash": "\\",
+	"bracketright": "]", "asciicircum": "^", "underscore": "_", "grave": "`", "braceleft": "{",
+	"bar": "|", "braceright": "}", "asciitilde": "~", "endash": "–", "emdash": "—", "bullet": "•",
+	"quotedblleft": "“", "quotedblright": "”", "quotedblbase": "„", "guillemotleft": "«",
+	"guillemotright": "»", "ellipsis": "…", "fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi",
+	"ffl": "ffl", "minus": "−", "multiply": "×", "degree": "°", "section": "§", "numero": "№",
+	"afii61352": "№", "nbspace": " ", "copyright": "©", "registered": "®",
+}
+
+// glyphText переводит имя глифа в текст: uniXXXX, uXXXX, кириллица afii100xx, AGL
+func glyphText(name string) (string, bool) {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i] // суффиксы вариантов: a.sc, one.oldstyle
+	}
+	if text, ok := glyphNames[name]; ok {
+		return text, true
+	}
+	if len(name) == 1 {
+		return name, true
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 {
+		if cp, err := strconv.ParseUint(name[3:7], 16, 32); err == nil {
+			return string(rune(cp)), true
+		}
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if cp, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return string(rune(cp)), true
+		}
+	}
+	if strings.HasPrefix(name, "afii") {
+		if n, err := strconv.Atoi(name[4:]); err == nil {
+			if r := afiiCyrillic(n); r != 0 {
+				return string(r), true
+			}
+		}
+	}
+	return "", false
+}
+
+// afiiCyrillic — имена afii10017-afii10097 для русского алфавита (Ё/ё стоят не по порядку)
+func afiiCyrillic(n int) rune {
+	switch {
+	case n >= 10017 && n <= 10022:
+		return rune(0x0410 + n - 10017)
+	case n == 10023:
+		return 'Ё'
+	case n >= 10024 && n <= 10049:
+		return rune(0x0416 + n - 10024)
+	case n >= 10065 && n <= 10070:
+		return rune(0x0430 + n - 10065)
+	case n == 10071:
+		return 'ё'
+	case n >= 10072 && n <= 10097:
+		return rune(0x0436 + n - 10072)
+	}
+	return 0
+}
+
+// parseToUnicode разбирает CMap /ToUnicode (bfchar и bfrange); длина кода берётся
+// из codespacerange
+func parseToUnicode(data []byte, codeBytes int) (map[uint32]string, int) {
+	cmap := make(map[uint32]string)
+	l := &pdfLexer{data: data}
+	var operands []interface{}
+
+	for {
+		obj, err := l.object()
+		if err != nil {
+			break
+		}
+		kw, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch kw {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if lo, ok := operands[0].([]byte); ok && len(lo) > 0 {
+					codeBytes = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].([]byte)
+				dst, ok2 := operands[i+1].([]byte)
+				if ok1 && ok2 {
+					cmap[bytesCode(src)] = utf16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].([]byte)
+				hi, ok2 := operands[i+1].([]byte)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := bytesCode(lo), bytesCode(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case []byte:
+					units := utf16Units(dst)
+					if len(units) == 0 {
+						continue
+					}
+					for c := start; c <= end; c++ {
+						u := append([]uint16(nil), units...)
+						u[len(u)-1] += uint16(c - start)
+						cmap[c] = string(utf16.Decode(u))
+					}
+				case []interface{}:
+					for j, item := range dst {
+						if b, ok := item.([]byte); ok && start+uint32(j) <= end {
+							cmap[start+uint32(j)] = utf16BE(b)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return cmap, codeBytes
+}
+
+func bytesCode(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return units
+}
+
+func utf16BE(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const pdfMaxFormDepth = 8
+
+// pdfMatrix — матрица преобразования [a b c d e f]; точки умножаются справа: p' = p × M
+type pdfMatrix [6]float64
+
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+func (m pdfMatrix) mul(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func pdfTranslate(tx, ty float64) pdfMatrix {
+	return pdfMatrix{1, 0, 0, 1, tx, ty}
+}
+
+// pdfTextRun — кусок текста, выведенный одним оператором, с координатами на странице
+type pdfTextRun struct {
+	x, y, endX float64
+	size       float64
+	text       string
+}
+
+type pdfGraphicsState struct {
+	ctm       pdfMatrix
+	font      *pdfFont
+	size      float64
+	charSpace float64
+	wordSpace float64
+	scale     float64
+	leading   float64
+	rise      float64
+}
+
+type pdfInterpreter struct {
+	file  *pdfFile
+	fonts map[pdfRef]*pdfFont
+	runs  []pdfTextRun
+}
+
+// extractPDF извлекает текст PDF по страницам; абзацы восстанавливаются по вертикальным
+// промежуткам между строками, для каждого абзаца запоминаются страница и положение
+func extractPDF(data []byte) (Document, error) {
+	file, err := parsePDF(data)
+	if err != nil {
+		return Document{}, err
+	}
+
+	interp := &pdfInterpreter{file: file, fonts: make(map[pdfRef]*pdfFont)}
+	var text strings.Builder
+	var blocks []Block
+	offset := 0
+
+	for n, page := range file.pages() {
+		interp.runs = interp.runs[:0]
+		state := pdfGraphicsState{ctm: pdfIdentity, scale: 1}
+		interp.execute(file.contents(page), page.resources, state, 0)
+
+		height := 792.0
+		if box := file.array(page.mediaBox); len(box) == 4 {
+			height = file.number(box[3]) - file.number(box[1])
+		}
+
+		for i, p := range layoutPDFRuns(interp.runs) {
+			if offset > 0 {
+				text.WriteString("\n\n")
+				offset += 2
+			}
+			length := utf8.RuneCountInString(p.text)
+			blocks = append(blocks, Block{
+				Start:     offset,
+				End:       offset + length,
+				Page:      n + 1,
+				Paragraph: i + 1,
+				X:         math.Round(p.x*10) / 10,
+				Y:         math.Round((height-p.y)*10) / 10,
+			})
+			text.WriteString(p.text)
+			offset += length
+		}
+	}
+
+	if len(blocks) == 0 {
+		return Document{}, errNoPDFText
+	}
+	return Document{Format: "pdf", Text: text.String(), Blocks: blocks}, nil
+}
+
+func (p *pdfInterpreter) font(resources pdfDict, name pdfName) *pdfFont {
+	v := p.file.dict(resources["Font"])[string(name)]
+	ref, cacheable := v.(pdfRef)
+	if cacheable {
+		if font, ok := p.fonts[ref]; ok {
+			return font
+		}
+	}
+	font := p.file.loadFont(v)
+	if cacheable {
+		p.fonts[ref] = font
+	}
+	return font
+}
+
+// execute выполняет поток содержимого, запоминая только вывод текста
+func (p *pdfInterpreter) execute(content []byte, resources pdfDict, gs pdfGraphicsState, depth int) {
+	l := &pdfLexer{data: content}
+	var stack []pdfGraphicsState
+	var operands []interface{}
+	var tm, tlm pdfMatrix
+
+	num := func(i int) float64 {
+		if i < len(operands) {
+			if f, ok := operands[i].(float64); ok {
+				return f
+			}
+		}
+		return 0
+	}
+	matrix := func() pdfMatrix {
+		var m pdfMatrix
+		for i := range m {
+			m[i] = num(i)
+		}
+		return m
+	}
+	nextLine := func(tx, ty float64) {
+		tlm = pdfTranslate(tx, ty).mul(tlm)
+		tm = tlm
+	}
+	show := func(s []byte) {
+		if gs.font == nil {
+			gs.font = p.file.loadFont(nil)
+		}
+		start := pdfMatrix{gs.size * gs.scale, 0, 0, gs.size, 0, gs.rise}.mul(tm).mul(gs.ctm)
+		device := tm.mul(gs.ctm)
+		size := gs.size * math.Hypot(device[2], device[3])
+
+		var b strings.Builder
+		for _, g := range gs.font.decode(s) {
+			b.WriteString(g.text)
+			tx := g.width/1000*gs.size + gs.charSpace
+			if g.space {
+				tx += gs.wordSpace
+			}
+			tm = pdfTranslate(tx*gs.scale, 0).mul(tm)
+		}
+		if b.Len() == 0 {
+			return
+		}
+		end := tm.mul(gs.ctm)
+		p.runs = append(p.runs, pdfTextRun{x: start[4], y: start[5], endX: end[4], size: size, text: b.String()})
+	}
+
+	for {
+		obj, err := l.object()
+		if err != nil {
+			return
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "q":
+			stack = append(stack, gs)
+		case "Q":
+			if len(stack) > 0 {
+				gs = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			gs.ctm = matrix().mul(gs.ctm)
+		case "BT":
+			tm, tlm = pdfIdentity, pdfIdentity
+		case "Tf":
+			if len(operands) == 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					gs.font = p.font(resources, name)
+					gs.size = num(1)
+				}
+			}
+		case "Tc":
+			gs.charSpace = num(0)
+		case "Tw":
+			gs.wordSpace = num(0)
+		case "Tz":
+			gs.scale = num(0) / 100
+		case "TL":
+			gs.leading = num(0)
+		case "Ts":
+			gs.rise = num(0)
+		case "Td":
+			nextLine(num(0), num(1))
+		case "TD":
+			gs.leading = -num(1)
+			nextLine(num(0), num(1))
+		case "Tm":
+			tm = matrix()
+			tlm = tm
+		case "T*":
+			nextLine(0, -gs.leading)
+		case "Tj", "'", "\"":
+			if op != "Tj" {
+				if op == "\"" {
+					gs.wordSpace, gs.charSpace = num(0), num(1)
+				}
+				nextLine(0, -gs.leading)
+			}
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].([]byte); ok {
+					show(s)
+				}
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			items, _ := operands[0].([]interface{})
+			for _, item := range items {
+				switch item := item.(type) {
+				case []byte:
+					show(item)
+				case float64:
+					tm = pdfTranslate(-item/1000*gs.size*gs.scale, 0).mul(tm)
+				}
+			}
+		case "Do":
+			if len(operands) == 1 && depth < pdfMaxFormDepth {
+				if name, ok := operands[0].(pdfName); ok {
+					p.form(resources, name, gs, depth)
+				}
+			}
+		case "BI":
+			skipInlineImage(l)
+		}
+		operands = operands[:0]
+	}
+}
+
+// form выполняет XObject-форму: в ней бывает весь текст страницы
+func (p *pdfInterpreter) form(resources pdfDict, name pdfName, gs pdfGraphicsState, depth int) {
+	s, ok := p.file.resolve(p.file.dict(resources["XObject"])[string(name)]).(*pdfStream)
+	if !ok || s.dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	data, err := p.file.decodeStream(s)
+	if err != nil {
+		return
+	}
+
+	if m := p.file.array(s.dict["Matrix"]); len(m) == 6 {
+		var matrix pdfMatrix
+		for i := range matrix {
+			matrix[i] = p.file.number(m[i])
+		}
+		gs.ctm = matrix.mul(gs.ctm)
+	}
+	if r := p.file.dict(s.dict["Resources"]); r != nil {
+		resources = r
+	}
+	p.execute(data, resources, gs, depth+1)
+}
+
+// skipInlineImage пропускает двоичные данные встроенного изображения BI ... ID <данные> EI
+func skipInlineImage(l *pdfLexer) {
+	for {
+		obj, err := l.object()
+		if err != nil {
+			return
+		}
+		if obj == pdfKeyword("ID") {
+			break
+		}
+	}
+	for i := l.pos; i+2 < len(l.data); i++ {
+		if isPDFSpace(l.data[i]) && l.data[i+1] == 'E' && l.data[i+2] == 'I' &&
+			(i+3 == len(l.data) || isPDFSpace(l.data[i+3])) {
+			l.pos = i + 3
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
+
+type pdfParagraph struct {
+	x, y float64 // начало первой строки
+	text string
+}
+
+// layoutPDFRuns собирает куски текста в строки и абзацы. Куски идут в порядке потока
+// содержимого; пробел вставляется по горизонтальному зазору, абзац начинается после
+// увеличенного межстрочного промежутка или при переходе вверх (новая колонка).
+func layoutPDFRuns(runs []pdfTextRun) []pdfParagraph {
+	var paragraphs []pdfParagraph
+	var cur *pdfParagraph
+	var line strings.Builder
+	var lineY, lineEnd, lineSize float64
+
+	flushLine := func() {
+		s := strings.TrimSpace(line.String())
+		line.Reset()
+		if s == "" || cur == nil {
+			return
+		}
+		if cur.text == "" {
+			cur.text = s
+			return
+		}
+		// Перенос слова по слогам: «сло-» + «во»
+		if r, _ := utf8.DecodeRuneInString(s); strings.HasSuffix(cur.text, "-") && unicode.IsLower(r) {
+			if prev, _ := utf8.DecodeLastRuneInString(strings.TrimSuffix(cur.text, "-")); unicode.IsLetter(prev) {
+				cur.text = strings.TrimSuffix(cur.text, "-") + s
+				return
+			}
+		}
+		cur.text += "\n" + s
+	}
+	flushParagraph := func() {
+		flushLine()
+		if cur != nil && cur.text != "" {
+			paragraphs = append(paragraphs, *cur)
+		}
+		cur = nil
+	}
+
+	for _, r := range runs {
+		size := math.Max(r.size, 1)
+		sameLine := cur != nil && math.Abs(r.y-lineY) < 0.5*math.Max(size, lineSize) && r.x > lineEnd-2*size
+
+		if sameLine {
+			if r.x-lineEnd > 0.2*size && !strings.HasPrefix(r.text, " ") && !strings.HasSuffix(line.String(), " ") {
+				line.WriteByte(' ')
+			}
+		} else {
+			gap := lineY - r.y
+			if cur == nil || gap > 1.7*math.Max(size, lineSize) || gap < -0.5*size {
+				flushParagraph()
+				cur = &pdfParagraph{x: r.x, y: r.y + size}
+			} else {
+				flushLine()
+			}
+			lineY = r.y
+		}
+
+		line.WriteString(r.text)
+		lineEnd = r.endX
+		lineSize = size
+	}
+	flushParagraph()
+	return paragraphs
+}
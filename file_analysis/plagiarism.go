@@ -1,189 +1,214 @@
 package main
 
 import (
-	"bytes"
 	"crypto/sha1"
 	"encoding/binary"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"strings"
+	"sort"
 	"time"
 )
 
-type Match struct {
-	ID        string  `json:"id"`
-	FileName  string  `json:"file_name"`
-	Sender    string  `json:"sender"`
-	Score     float64 `json:"score"`
-	Timestamp string  `json:"timestamp"`
+// Passage — совпавший фрагмент: смещения в символах в проверяемой и в найденной работе
+// и, если документ размечен (PDF), страница и абзац начала фрагмента
+type Passage struct {
+	Start         int       `json:"start"`
+	End           int       `json:"end"`
+	OtherStart    int       `json:"other_start"`
+	OtherEnd      int       `json:"other_end"`
+	Score         float64   `json:"score"`
+	Location      *Location `json:"location,omitempty"`
+	OtherLocation *Location `json:"other_location,omitempty"`
 }
 
-func storeDocument(sender, workID, fileName string, vector []float32) (string, error) {
-	h := sha1.Sum([]byte(fmt.Sprintf("%s_%s_%s", sender, workID, fileName)))
-	docID := int64(binary.BigEndian.Uint64(h[:8]) & 0x7FFFFFFFFFFFFFFF)
-	docIDStr := fmt.Sprintf("%d", docID)
-
-	point := map[string]interface{}{
-		"id":     docID,
-		"vector": vector,
-		"payload": map[string]interface{}{
-			"sender":    sender,
-			"work_id":   workID,
-			"file_name": fileName,
-			"timestamp": time.Now().Format(time.RFC3339),
-		},
-	}
-
-	payload := map[string]interface{}{
-		"points": []map[string]interface{}{point},
-	}
-
-	url := fmt.Sprintf("%s/collections/%s/points?wait=true",
-		strings.TrimSuffix(config.QdrantURL, "/"),
-		config.CollectionName)
+type Match struct {
+	ID        string    `json:"id"`
+	FileName  string    `json:"file_name"`
+	Sender    string    `json:"sender"`
+	WorkID    string    `json:"work_id,omitempty"`
+	Score     float64   `json:"score"`
+	Coverage  float64   `json:"coverage,omitempty"`
+	Jaccard   float64   `json:"jaccard,omitempty"`
+	Timestamp string    `json:"timestamp"`
+	Passages  []Passage `json:"passages,omitempty"`
+}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %w", err)
-	}
+// documentID — стабильный ID сдачи, на него ссылаются точки-фрагменты в хранилище векторов
+func documentID(sender, workID, fileName string) int64 {
+	return hashID(fmt.Sprintf("%s_%s_%s", sender, workID, fileName))
+}
 
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
+func chunkPointID(docID int64, index int) int64 {
+	return hashID(fmt.Sprintf("%d_chunk_%d", docID, index))
+}
 
-	client := &http.Client{Timeout: 15 * time.Second}
+func hashID(s string) int64 {
+	h := sha1.Sum([]byte(s))
+	return int64(binary.BigEndian.Uint64(h[:8]) & 0x7FFFFFFFFFFFFFFF)
+}
 
-	var resp *http.Response
-	maxRetries := 3
-	var lastErr error
+// storeChunks заменяет фрагменты сдачи в хранилище векторов: старые точки удаляются,
+// каждый фрагмент сохраняется отдельной точкой со ссылкой на сдачу. vectors — векторы
+// фрагментов по именам; точка получает только посчитанные.
+func storeChunks(docID int64, sender, workID, fileName string, chunks []Chunk, vectors map[string][][]float32) error {
+	submissionID := fmt.Sprintf("%d", docID)
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		resp, err = client.Do(req)
-		if err == nil {
-			break
-		}
-		lastErr = err
-		time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
+	previous := VectorFilter{Must: map[string]interface{}{"submission_id": submissionID}}
+	if err := vectorStore.Delete(previous); err != nil {
+		return fmt.Errorf("failed to delete previous chunks: %w", err)
 	}
 
-	if err != nil {
-		return "", fmt.Errorf("request to Qdrant failed after %d attempts: %w", maxRetries, lastErr)
+	if len(chunks) == 0 {
+		return nil
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+	timestamp := time.Now().Format(time.RFC3339)
+	points := make([]VectorPoint, len(chunks))
+	for i, chunk := range chunks {
+		named := make(map[string][]float32, len(vectors))
+		for name, v := range vectors {
+			named[name] = v[i]
+		}
+		points[i] = VectorPoint{
+			ID:      chunkPointID(docID, chunk.Index),
+			Vectors: named,
+			Payload: map[string]interface{}{
+				"kind":          "chunk",
+				"submission_id": submissionID,
+				"chunk_index":   chunk.Index,
+				"start":         chunk.Start,
+				"end":           chunk.End,
+				"language":      chunk.Language,
+				"sender":        sender,
+				"work_id":       workID,
+				"file_name":     fileName,
+				"timestamp":     timestamp,
+			},
+		}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Qdrant API error [%d]: %s - %s",
-			resp.StatusCode,
-			http.StatusText(resp.StatusCode),
-			strings.TrimSpace(string(body)))
+	if err := vectorStore.Upsert(points); err != nil {
+		return fmt.Errorf("failed to store chunks: %w", err)
 	}
-
-	return docIDStr, nil
+	return nil
 }
 
-func findSimilarDocuments(workID, excludeSender string, vector []float32) ([]Match, error) {
-	url := fmt.Sprintf("%s/collections/%s/points/search",
-		strings.TrimSuffix(config.QdrantURL, "/"),
-		config.CollectionName)
-
-	payload := map[string]interface{}{
-		"vector":          vector,
-		"limit":           5,
-		"with_payload":    true,
-		"with_vectors":    false,
-		"score_threshold": 0.7,
-		"filter": map[string]interface{}{
-			"must": []map[string]interface{}{
-				{
-					"key": "work_id",
-					"match": map[string]interface{}{
-						"value": workID,
-					},
-				},
-			},
-			"must_not": []map[string]interface{}{
-				{
-					"key": "sender",
-					"match": map[string]interface{}{
-						"value": excludeSender,
-					},
-				},
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal search payload: %w", err)
+// findSimilarChunks ищет для каждого фрагмента похожие фрагменты чужих работ того же задания
+// по вектору vectorName и агрегирует попадания по сдачам: Score — средняя схожесть совпавших
+// фрагментов, Coverage — доля фрагментов проверяемой работы, нашедших пару.
+func findSimilarChunks(workID, excludeSender string, chunks []Chunk, vectorName string, vectors [][]float32, thresholds Thresholds) ([]Match, error) {
+	if len(chunks) == 0 {
+		return nil, nil
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create search request: %w", err)
+	filter := VectorFilter{
+		Must:    map[string]interface{}{"work_id": workID, "kind": "chunk"},
+		MustNot: map[string]interface{}{"sender": excludeSender},
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("search request failed: %w", err)
+	queries := make([]VectorQuery, len(vectors))
+	for i, vector := range vectors {
+		queries[i] = VectorQuery{
+			Vector:   vectorName,
+			Values:   vector,
+			Limit:    thresholds.SearchLimit,
+			MinScore: thresholds.Suspicious,
+			Filter:   filter,
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	result, err := vectorStore.Search(queries)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("batch search failed: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
-	}
+	matches := make(map[string]*Match)
+	best := make(map[string]map[int]Passage)
 
-	type searchResultItem struct {
-		ID      uint64      `json:"id"`
-		Version int         `json:"version"`
-		Score   float64     `json:"score"`
-		Payload interface{} `json:"payload"`
+	for i, hits := range result {
+		if i >= len(chunks) {
+			break
+		}
+		chunk := chunks[i]
+
+		for _, hit := range hits {
+			submissionID := payloadString(hit.Payload, "submission_id")
+			if submissionID == "" {
+				continue
+			}
+
+			if _, ok := matches[submissionID]; !ok {
+				matches[submissionID] = &Match{
+					ID:        submissionID,
+					FileName:  payloadString(hit.Payload, "file_name"),
+					Sender:    payloadString(hit.Payload, "sender"),
+					Timestamp: payloadString(hit.Payload, "timestamp"),
+				}
+				best[submissionID] = make(map[int]Passage)
+			}
+
+			if prev, ok := best[submissionID][chunk.Index]; ok && prev.Score >= hit.Score {
+				continue
+			}
+			best[submissionID][chunk.Index] = Passage{
+				Start:      chunk.Start,
+				End:        chunk.End,
+				OtherStart: payloadInt(hit.Payload, "start"),
+				OtherEnd:   payloadInt(hit.Payload, "end"),
+				Score:      hit.Score,
+			}
+		}
 	}
 
-	var result struct {
-		Result []searchResultItem `json:"result"`
-		Status string             `json:"status"`
-		Time   float64            `json:"time"`
+	var list []Match
+	for submissionID, match := range matches {
+		var sum float64
+		passages := make([]Passage, 0, len(best[submissionID]))
+		for _, p := range best[submissionID] {
+			sum += p.Score
+			passages = append(passages, p)
+		}
+		match.Score = sum / float64(len(passages))
+		match.Coverage = float64(len(passages)) / float64(len(chunks))
+		match.Passages = mergePassages(passages)
+		list = append(list, *match)
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	sortMatches(list)
+	if len(list) > thresholds.SearchLimit {
+		list = list[:thresholds.SearchLimit]
 	}
+	return list, nil
+}
 
-	var matches []Match
-	for _, item := range result.Result {
-		payload, ok := item.Payload.(map[string]interface{})
-		if !ok {
-			continue
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Coverage != matches[j].Coverage {
+			return matches[i].Coverage > matches[j].Coverage
 		}
+		return matches[i].Jaccard > matches[j].Jaccard
+	})
+}
 
-		fileName, _ := payload["file_name"].(string)
-		sender, _ := payload["sender"].(string)
-		timestamp, _ := payload["timestamp"].(string)
-
-		matches = append(matches, Match{
-			ID:        fmt.Sprintf("%d", item.ID),
-			FileName:  fileName,
-			Sender:    sender,
-			Score:     item.Score,
-			Timestamp: timestamp,
-		})
+// mergePassages склеивает перекрывающиеся соседние фрагменты (окна идут с перекрытием)
+func mergePassages(passages []Passage) []Passage {
+	sort.Slice(passages, func(i, j int) bool { return passages[i].Start < passages[j].Start })
+
+	var merged []Passage
+	for _, p := range passages {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if p.Start <= last.End && p.OtherStart <= last.OtherEnd && p.OtherEnd >= last.OtherStart {
+				last.End = max(last.End, p.End)
+				last.OtherStart = min(last.OtherStart, p.OtherStart)
+				last.OtherEnd = max(last.OtherEnd, p.OtherEnd)
+				last.Score = max(last.Score, p.Score)
+				continue
+			}
+		}
+		merged = append(merged, p)
 	}
-
-	return matches, nil
+	return merged
 }
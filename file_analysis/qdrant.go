@@ -7,15 +7,31 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 )
 
-func initializeQdrant() error {
+// qdrantStore — коллекция Qdrant через REST API
+type qdrantStore struct {
+	baseURL    string
+	collection string
+	client     *http.Client
+}
+
+func newQdrantStore(baseURL, collection string) *qdrantStore {
+	return &qdrantStore{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		collection: collection,
+		client:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (q *qdrantStore) Init(vectors map[string]int) error {
 	log.Println("Waiting for Qdrant to be ready...")
 	maxRetries := 30
 	for i := 0; i < maxRetries; i++ {
-		resp, err := http.Get(config.QdrantURL + "/collections")
+		resp, err := http.Get(q.baseURL + "/collections")
 		if err == nil {
 			resp.Body.Close()
 			if resp.StatusCode == http.StatusOK {
@@ -29,35 +45,83 @@ func initializeQdrant() error {
 		time.Sleep(1 * time.Second)
 	}
 
-	url := fmt.Sprintf("%s/collections/%s", strings.TrimSuffix(config.QdrantURL, "/"), config.CollectionName)
+	url := fmt.Sprintf("%s/collections/%s", q.baseURL, q.collection)
 
 	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to check collection: %w", err)
 	}
-	resp.Body.Close()
+	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return createQdrantCollection(url)
-	} else if resp.StatusCode == http.StatusOK {
-		log.Printf("Collection %s already exists, skipping creation", config.CollectionName)
-	} else {
+		return q.createCollection(url, vectors)
+	} else if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("unexpected status code while checking collection: %d", resp.StatusCode)
 	}
 
+	// Векторы коллекции задаются при создании; после смены модели эмбеддингов или размерности
+	// запасного вектора старую коллекцию нужно удалить или указать другую в COLLECTION_NAME
+	var info struct {
+		Result struct {
+			Config struct {
+				Params struct {
+					Vectors map[string]json.RawMessage `json:"vectors"`
+				} `json:"params"`
+			} `json:"config"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return fmt.Errorf("failed to parse collection info: %w", err)
+	}
+	existing := info.Result.Config.Params.Vectors
+	if _, ok := existing["size"]; ok {
+		return fmt.Errorf("collection %s has a single unnamed vector, but vectors %q and %q are required; %s",
+			q.collection, modelVectorName, hashingVectorName, reindexHint)
+	}
+	for name, size := range vectors {
+		var params struct {
+			Size int `json:"size"`
+		}
+		if raw, ok := existing[name]; !ok || json.Unmarshal(raw, &params) != nil {
+			return fmt.Errorf("collection %s has no vector %q; %s", q.collection, name, reindexHint)
+		}
+		if params.Size != size {
+			return fmt.Errorf("collection %s has size %d for vector %q, expected %d; %s",
+				q.collection, params.Size, name, size, reindexHint)
+		}
+	}
+	log.Printf("Collection %s already exists, skipping creation", q.collection)
 	return nil
 }
 
-func createQdrantCollection(url string) error {
-	log.Printf("Collection %s not found, creating...", config.CollectionName)
+func (q *qdrantStore) Reset(vectors map[string]int) error {
+	url := fmt.Sprintf("%s/collections/%s", q.baseURL, q.collection)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete collection [%d]: %s", resp.StatusCode, string(body))
+	}
+	log.Printf("Deleted collection %s", q.collection)
+	return q.createCollection(url, vectors)
+}
+
+func (q *qdrantStore) createCollection(url string, vectors map[string]int) error {
+	log.Printf("Collection %s not found, creating with vector sizes %v...", q.collection, vectors)
 
-	collectionConfig := map[string]interface{}{
-		"vectors": map[string]interface{}{
-			"size":     384,
-			"distance": "Cosine",
-		},
+	params := make(map[string]interface{})
+	for name, size := range vectors {
+		params[name] = map[string]interface{}{"size": size, "distance": "Cosine"}
 	}
+	collectionConfig := map[string]interface{}{"vectors": params}
 
 	jsonData, err := json.Marshal(collectionConfig)
 	if err != nil {
@@ -80,11 +144,209 @@ func createQdrantCollection(url string) error {
 	body, _ := ioutil.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
 		if strings.Contains(string(body), "already exists") {
-			log.Printf("Collection %s already exists, continuing...", config.CollectionName)
+			log.Printf("Collection %s already exists, continuing...", q.collection)
 			return nil
 		}
 		return fmt.Errorf("failed to create collection [%d]: %s", resp.StatusCode, string(body))
 	}
-	log.Printf("Successfully created collection %s", config.CollectionName)
+	log.Printf("Successfully created collection %s", q.collection)
+	return nil
+}
+
+func (q *qdrantStore) Upsert(points []VectorPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	list := make([]map[string]interface{}, len(points))
+	for i, p := range points {
+		list[i] = map[string]interface{}{
+			"id":      p.ID,
+			"vector":  p.Vectors,
+			"payload": p.Payload,
+		}
+	}
+	payload := map[string]interface{}{"points": list}
+	return q.do(http.MethodPut, "/points?wait=true", payload, nil)
+}
+
+func (q *qdrantStore) Search(queries []VectorQuery) ([][]ScoredPoint, error) {
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	searches := make([]map[string]interface{}, len(queries))
+	for i, query := range queries {
+		searches[i] = map[string]interface{}{
+			"vector":          map[string]interface{}{"name": query.Vector, "vector": query.Values},
+			"limit":           query.Limit,
+			"with_payload":    true,
+			"with_vectors":    false,
+			"score_threshold": query.MinScore,
+			"filter":          qdrantFilter(query.Filter),
+		}
+	}
+
+	var result struct {
+		Result [][]struct {
+			ID      int64                  `json:"id"`
+			Score   float64                `json:"score"`
+			Payload map[string]interface{} `json:"payload"`
+		} `json:"result"`
+	}
+	payload := map[string]interface{}{"searches": searches}
+	if err := q.do(http.MethodPost, "/points/search/batch", payload, &result); err != nil {
+		return nil, err
+	}
+
+	hits := make([][]ScoredPoint, len(queries))
+	for i := range hits {
+		if i >= len(result.Result) {
+			break
+		}
+		for _, hit := range result.Result[i] {
+			hits[i] = append(hits[i], ScoredPoint{ID: hit.ID, Score: hit.Score, Payload: hit.Payload})
+		}
+	}
+	return hits, nil
+}
+
+func (q *qdrantStore) Delete(filter VectorFilter) error {
+	payload := map[string]interface{}{"filter": qdrantFilter(filter)}
+	return q.do(http.MethodPost, "/points/delete?wait=true", payload, nil)
+}
+
+func (q *qdrantStore) Scroll(filter VectorFilter, fn func(VectorPoint) error) error {
+	var offset interface{}
+	for {
+		request := map[string]interface{}{
+			"filter":       qdrantFilter(filter),
+			"limit":        256,
+			"with_payload": true,
+			"with_vector":  true,
+		}
+		if offset != nil {
+			request["offset"] = offset
+		}
+
+		var result struct {
+			Result struct {
+				Points []struct {
+					ID      int64                  `json:"id"`
+					Vector  json.RawMessage        `json:"vector"`
+					Payload map[string]interface{} `json:"payload"`
+				} `json:"points"`
+				NextPageOffset interface{} `json:"next_page_offset"`
+			} `json:"result"`
+		}
+		if err := q.do(http.MethodPost, "/points/scroll", request, &result); err != nil {
+			return err
+		}
+		for _, p := range result.Result.Points {
+			// Безымянный вектор старой коллекции отдаётся под пустым именем
+			vectors := make(map[string][]float32)
+			if bytes.HasPrefix(bytes.TrimSpace(p.Vector), []byte("[")) {
+				var v []float32
+				if err := json.Unmarshal(p.Vector, &v); err != nil {
+					return fmt.Errorf("failed to decode point %d: %w", p.ID, err)
+				}
+				vectors[""] = v
+			} else if len(p.Vector) > 0 {
+				if err := json.Unmarshal(p.Vector, &vectors); err != nil {
+					return fmt.Errorf("failed to decode point %d: %w", p.ID, err)
+				}
+			}
+			if err := fn(VectorPoint{ID: p.ID, Vectors: vectors, Payload: p.Payload}); err != nil {
+				return err
+			}
+		}
+		if result.Result.NextPageOffset == nil {
+			return nil
+		}
+		offset = result.Result.NextPageOffset
+	}
+}
+
+// qdrantFilter переводит фильтр в условия match Qdrant
+func qdrantFilter(f VectorFilter) map[string]interface{} {
+	conditions := func(fields map[string]interface{}) []map[string]interface{} {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		list := make([]map[string]interface{}, len(keys))
+		for i, k := range keys {
+			list[i] = matchCondition(k, fields[k])
+		}
+		return list
+	}
+
+	filter := make(map[string]interface{})
+	if len(f.Must) > 0 {
+		filter["must"] = conditions(f.Must)
+	}
+	if len(f.MustNot) > 0 {
+		filter["must_not"] = conditions(f.MustNot)
+	}
+	return filter
+}
+
+func matchCondition(key string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   key,
+		"match": map[string]interface{}{"value": value},
+	}
+}
+
+// do выполняет запрос к коллекции с повторами при сетевых ошибках
+func (q *qdrantStore) do(method, path string, payload interface{}, out interface{}) error {
+	url := fmt.Sprintf("%s/collections/%s%s", q.baseURL, q.collection, path)
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	var resp *http.Response
+	maxRetries := 3
+	var lastErr error
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		req, err := http.NewRequest(method, url, bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err = q.client.Do(req)
+		if err == nil {
+			lastErr = nil
+			break
+		}
+		lastErr = err
+		time.Sleep(time.Duration(attempt+1) * 500 * time.Millisecond)
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("request to Qdrant failed after %d attempts: %w", maxRetries, lastErr)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Qdrant API error [%d]: %s - %s",
+			resp.StatusCode,
+			http.StatusText(resp.StatusCode),
+			strings.TrimSpace(string(body)))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reindexHint подсказывает, как перестроить хранилище после смены модели или формата точек
+const reindexHint = "rebuild it from stored submissions with `server reindex`"
+
+// reindexSubmissions пересоздаёт хранилище векторов и заново сохраняет фрагменты сдач, которые
+// в нём были, по их текстам из TEXT_DIR. Нужен после смены модели эмбеддингов или FALLBACK_DIM
+// и для коллекций, созданных до именованных векторов. Отчёты не меняются. Сервис эмбеддингов
+// должен быть доступен: фрагменты без вектора модели не нашлись бы обычным поиском.
+func reindexSubmissions() error {
+	// Сдачи берутся из самого хранилища: код и сдачи без анализатора embedding фрагментов не имеют.
+	// Список сохраняется до очистки хранилища, чтобы прерванный reindex можно было повторить.
+	if err := os.MkdirAll(config.TextDir, 0755); err != nil {
+		return fmt.Errorf("failed to create text directory: %w", err)
+	}
+	pending := filepath.Join(config.TextDir, "reindex.pending")
+	var ids []string
+	if data, err := ioutil.ReadFile(pending); err == nil {
+		ids = strings.Fields(string(data))
+		log.Printf("Resuming interrupted reindex")
+	} else if os.IsNotExist(err) {
+		if ids, err = storedSubmissions(); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(pending, []byte(strings.Join(ids, "\n")), 0644); err != nil {
+			return fmt.Errorf("failed to save reindex list: %w", err)
+		}
+	} else {
+		return fmt.Errorf("failed to read reindex list: %w", err)
+	}
+	log.Printf("Reindexing %d submissions", len(ids))
+
+	if err := vectorStore.Reset(collectionVectors()); err != nil {
+		return fmt.Errorf("failed to reset vector store: %w", err)
+	}
+
+	reindexed := 0
+	for _, id := range ids {
+		sub, err := loadSubmission(id)
+		if err != nil {
+			log.Printf("Skipping submission %s: %v", id, err)
+			continue
+		}
+		docID, err := strconv.ParseInt(sub.ID, 10, 64)
+		if err != nil {
+			log.Printf("Skipping submission %s: invalid id", id)
+			continue
+		}
+
+		language, _ := detectLanguages(sub.Text)
+		chunks, vectors, method := embedChunks(sub.FileName, sub.Text, language)
+		if method != modelVectorName {
+			return fmt.Errorf("%w after %d of %d submissions; run reindex again", errEmbeddingsUnavailable, reindexed, len(ids))
+		}
+		if err := storeChunks(docID, sub.Sender, sub.WorkID, sub.FileName, chunks, vectors); err != nil {
+			return fmt.Errorf("failed to store submission %s: %w", id, err)
+		}
+		reindexed++
+	}
+	log.Printf("Reindexed %d submissions", reindexed)
+	return os.Remove(pending)
+}
+
+// storedSubmissions — ID сдач, у которых в хранилище есть фрагменты
+func storedSubmissions() ([]string, error) {
+	seen := make(map[string]bool)
+	err := vectorStore.Scroll(VectorFilter{Must: map[string]interface{}{"kind": "chunk"}}, func(p VectorPoint) error {
+		if id := payloadString(p.Payload, "submission_id"); id != "" {
+			seen[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored submissions: %w", err)
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -4,38 +4,97 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"time"
 )
 
 type Report struct {
-	FileName    string    `json:"file_name"`
-	Sender      string    `json:"sender"`
-	WorkID      string    `json:"work_id"`
-	Plagiarized bool      `json:"plagiarized"`
-	Similarity  float64   `json:"similarity,omitempty"`
-	Timestamp   time.Time `json:"timestamp"`
-	Error       string    `json:"error,omitempty"`
+	ID                string            `json:"id,omitempty"`
+	FileName          string            `json:"file_name"`
+	Sender            string            `json:"sender"`
+	SenderName        string            `json:"sender_name,omitempty"`
+	WorkID            string            `json:"work_id"`
+	Format            string            `json:"format,omitempty"`
+	Encoding          string            `json:"encoding,omitempty"`
+	Sections          []string          `json:"sections,omitempty"`
+	Language          string            `json:"language,omitempty"`  // основной язык текста
+	Languages         []string          `json:"languages,omitempty"` // языки смешанного документа, по убыванию объёма
+	Obfuscation       *Obfuscation      `json:"obfuscation,omitempty"`
+	Plagiarized       bool              `json:"plagiarized"`
+	Verdict           Verdict           `json:"verdict,omitempty"`
+	Similarity        float64           `json:"similarity,omitempty"`
+	Thresholds        *Thresholds       `json:"thresholds,omitempty"`
+	Matches           []Match           `json:"matches,omitempty"`
+	EmbeddingMethod   string            `json:"embedding_method,omitempty"` // model или hashing (сервис эмбеддингов был недоступен)
+	Rescored          bool              `json:"rescored,omitempty"`         // оценка пересчитана моделью после восстановления сервиса
+	LexicalOverlap    float64           `json:"lexical_overlap,omitempty"`
+	LexicalMatches    []LexicalMatch    `json:"lexical_matches,omitempty"`
+	Mode              string            `json:"mode,omitempty"`
+	CodeLanguage      string            `json:"code_language,omitempty"`
+	CodeOverlap       float64           `json:"code_overlap,omitempty"`
+	CodeMatches       []CodeMatch       `json:"code_matches,omitempty"`
+	StructuralScore   float64           `json:"structural_score,omitempty"`
+	StructuralMatches []StructuralMatch `json:"structural_matches,omitempty"`
+	Files             []Report          `json:"files,omitempty"`         // отчёты по файлам архива
+	SkippedFiles      []string          `json:"skipped_files,omitempty"` // файлы архива, которые не проверялись
+	Late              bool              `json:"late,omitempty"`
+	Timestamp         time.Time         `json:"timestamp"`
+	WordCloud         string            `json:"word_cloud,omitempty"`
+	Error             string            `json:"error,omitempty"`
 }
 
-func saveReport(report Report) error {
+// saveReport сохраняет новый отчёт и возвращает путь к его файлу
+func saveReport(report Report) (string, error) {
 	reportPath := filepath.Join(config.DataDir, fmt.Sprintf("report_%s_%s_%d.json",
 		report.Sender,
 		report.WorkID,
 		time.Now().UnixNano()))
 
+	return reportPath, writeReport(reportPath, report)
+}
+
+// writeReport пишет отчёт во временный файл и переименовывает его: пересчёт переписывает
+// отчёты в фоне, и /reports не должен прочитать файл наполовину
+func writeReport(reportPath string, report Report) error {
 	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal report: %w", err)
 	}
 
-	if err := ioutil.WriteFile(reportPath, data, 0644); err != nil {
+	tmp, err := ioutil.TempFile(filepath.Dir(reportPath), filepath.Base(reportPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), reportPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
 		return fmt.Errorf("failed to write report: %w", err)
 	}
-
 	return nil
 }
 
+func loadReport(reportPath string) (Report, error) {
+	var report Report
+	data, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		return report, err
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to parse report %s: %w", reportPath, err)
+	}
+	return report, nil
+}
+
 func getCurrentTime() time.Time {
 	return time.Now()
 }
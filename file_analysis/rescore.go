@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errEmbeddingsUnavailable = errors.New("embeddings service is still unavailable")
+
+// rescoreEntry — отчёт, оценка которого посчитана запасным хеширующим вектором; хранится
+// в {RESCORE_DIR}/{имя отчёта}, пока модель не пересчитает оценку
+type rescoreEntry struct {
+	ReportPath string `json:"report_path"`
+}
+
+func queueRescore(reportPath string) {
+	data, err := json.Marshal(rescoreEntry{ReportPath: reportPath})
+	if err != nil {
+		log.Printf("Failed to queue rescore of %s: %v", reportPath, err)
+		return
+	}
+	path := filepath.Join(config.RescoreDir, filepath.Base(reportPath))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Printf("Failed to queue rescore of %s: %v", reportPath, err)
+		return
+	}
+	log.Printf("Report %s queued for rescoring once embeddings are available", reportPath)
+}
+
+// runRescorer раз в interval проверяет, вернулся ли сервис эмбеддингов, и пересчитывает
+// отложенные отчёты
+func runRescorer(interval time.Duration) {
+	for range time.Tick(interval) {
+		rescorePending()
+	}
+}
+
+func rescorePending() {
+	files, err := ioutil.ReadDir(config.RescoreDir)
+	if err != nil {
+		log.Printf("Failed to read rescore queue: %v", err)
+		return
+	}
+	var pending []string
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".json") {
+			pending = append(pending, filepath.Join(config.RescoreDir, f.Name()))
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	// Кэш здесь не подходит: нужен настоящий ответ сервиса
+	if _, err := embedder.Embed([]string{"health check"}); err != nil {
+		log.Printf("Embeddings still unavailable, %d reports wait for rescoring", len(pending))
+		return
+	}
+
+	for _, path := range pending {
+		err := rescoreQueued(path)
+		switch {
+		case errors.Is(err, errEmbeddingsUnavailable):
+			log.Printf("Embeddings became unavailable during rescoring: %v", err)
+			return
+		case errors.Is(err, os.ErrNotExist):
+			// Отчёт или текст сдачи удалены — пересчитывать нечего
+			log.Printf("Dropping rescore entry %s: %v", path, err)
+		case err != nil:
+			log.Printf("Failed to rescore %s, will retry: %v", path, err)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("Failed to remove rescore entry %s: %v", path, err)
+		}
+	}
+}
+
+func rescoreQueued(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entry rescoreEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return fmt.Errorf("failed to parse rescore entry: %w", err)
+	}
+
+	report, err := loadReport(entry.ReportPath)
+	if err != nil {
+		return err
+	}
+	if err := rescoreReport(&report); err != nil {
+		return err
+	}
+	if err := writeReport(entry.ReportPath, report); err != nil {
+		return err
+	}
+	log.Printf("Rescored %s: similarity %.3f, verdict %s", entry.ReportPath, report.Similarity, report.Verdict)
+	return nil
+}
+
+// rescoreReport пересчитывает моделью оценку отчёта, а у архива — оценки файлов, проверенных
+// хеширующим вектором, и итог архива
+func rescoreReport(report *Report) error {
+	if report.Mode != "archive" {
+		return rescoreSubmission(report)
+	}
+
+	report.Similarity = 0
+	report.Verdict = VerdictClean
+	for i := range report.Files {
+		if report.Files[i].EmbeddingMethod == hashingVectorName {
+			if err := rescoreSubmission(&report.Files[i]); err != nil {
+				return err
+			}
+		}
+		report.Similarity = max(report.Similarity, report.Files[i].Similarity)
+		report.Verdict = worseVerdict(report.Verdict, report.Files[i].Verdict)
+	}
+	report.EmbeddingMethod = modelVectorName
+	report.Rescored = true
+	report.Plagiarized = report.Verdict == VerdictPlagiarized
+	flagObfuscation(report)
+	return nil
+}
+
+// reportThresholds — пороги, с которыми был построен отчёт; в отчётах до появления
+// полос запасного вектора и кода они выводятся из основных порогов отчёта
+func reportThresholds(report *Report) Thresholds {
+	if report.Thresholds == nil {
+		return resolveThresholds(nil)
+	}
+	t := *report.Thresholds
+	t.deriveBands()
+	return t
+}
+
+// rescoreSubmission повторяет анализ эмбеддингов по сохранённому тексту сдачи. Совпадения,
+// найденные MinHash, сохраняются, остальные результаты отчёта не меняются.
+func rescoreSubmission(report *Report) error {
+	sub, err := loadSubmission(report.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load submission %s: %w", report.ID, err)
+	}
+	docID, err := strconv.ParseInt(report.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid submission id %q: %w", report.ID, err)
+	}
+	thresholds := reportThresholds(report)
+
+	var minhash []Match
+	for _, m := range report.Matches {
+		if m.Jaccard > 0 {
+			minhash = append(minhash, Match{
+				ID:        m.ID,
+				FileName:  m.FileName,
+				Sender:    m.Sender,
+				WorkID:    m.WorkID,
+				Jaccard:   m.Jaccard,
+				Timestamp: m.Timestamp,
+			})
+		}
+	}
+
+	rescored := *report
+	rescored.Matches = nil
+	if err := analyzeEmbedding(&rescored, docID, sub.Text, report.Language, thresholds); err != nil {
+		return err
+	}
+	if rescored.EmbeddingMethod != modelVectorName {
+		return errEmbeddingsUnavailable
+	}
+
+	for _, m := range minhash {
+		merged := false
+		for i := range rescored.Matches {
+			if rescored.Matches[i].ID == m.ID {
+				rescored.Matches[i].Jaccard = m.Jaccard
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			rescored.Matches = append(rescored.Matches, m)
+		}
+	}
+	sortMatches(rescored.Matches)
+	locatePassages(&rescored, sub.Blocks)
+
+	rescored.Rescored = true
+	scoreReport(&rescored, thresholds)
+
+	*report = rescored
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+// WorkSettings — настройки проверки задания, которые gateway передаёт вместе с запросом
+type WorkSettings struct {
+	SuspiciousThreshold float64  `json:"suspicious_threshold,omitempty"`
+	SimilarityThreshold float64  `json:"similarity_threshold,omitempty"`
+	Analyzers           []string `json:"analyzers,omitempty"`
+	Mode                string   `json:"mode,omitempty"`
+}
+
+// Анализаторы, включённые по умолчанию, если задание их не перечисляет
+var defaultAnalyzers = []string{"embedding", "lexical", "minhash", "wordcloud", "code", "structure"}
+
+func (s *WorkSettings) enabled(analyzer string) bool {
+	analyzers := defaultAnalyzers
+	if s != nil && len(s.Analyzers) > 0 {
+		analyzers = s.Analyzers
+	}
+	for _, name := range analyzers {
+		if name == analyzer {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// lemmas — необязательный словарь «словоформа → лемма» (LEMMA_DICT); помогает там, где
+// стеммер бессилен: «люди»/«человек», «went»/«go». lemmaDigest — SHA-256 файла словаря
+// для ключей кэша отпечатков.
+var (
+	lemmas      map[string]string
+	lemmaDigest string
+)
+
+// loadLemmas читает словарь: по строке на словоформу, форма и лемма через пробел или табуляцию
+func loadLemmas(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lemma dictionary: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	dict := make(map[string]string)
+	scanner := bufio.NewScanner(io.TeeReader(f, h))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		dict[strings.ToLower(fields[0])] = strings.ToLower(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lemma dictionary: %w", err)
+	}
+	lemmaDigest = hex.EncodeToString(h.Sum(nil))
+	return dict, nil
+}
+
+// normalizeWord сводит словоформу в нижнем регистре к основе: сначала лемма из словаря,
+// затем стеммер языка участка (см. languageFor). Слова с цифрами и смешанным алфавитом,
+// как и слова языков без стеммера, не меняются.
+func normalizeWord(word, lang string) string {
+	if lemma, ok := lemmas[word]; ok {
+		word = lemma
+	}
+	if l := languageFor(word, lang); l != nil && l.Stem != nil {
+		return l.Stem(word)
+	}
+	return word
+}
+
+// stemTokens заменяет текст токенов их основами по языкам участков, сохраняя смещения
+func stemTokens(tokens []Token, spans []languageSpan) []Token {
+	for i := range tokens {
+		tokens[i].Text = normalizeWord(tokens[i].Text, languageAt(spans, tokens[i].Start))
+	}
+	return tokens
+}
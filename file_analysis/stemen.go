@@ -0,0 +1,276 @@
+package main
+
+import "strings"
+
+// Стеммер Snowball для английского языка (Porter2, snowballstem.org/algorithms/english/stemmer.html)
+
+var enExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias",
+	"andes": "andes",
+}
+
+// Слова, которые после шага 1a больше не изменяются
+var enInvariantAfter1a = map[string]bool{
+	"inning": true, "outing": true, "canning": true, "herring": true, "earring": true,
+	"proceed": true, "exceed": true, "succeed": true,
+}
+
+var (
+	enStep2 = []struct{ suffix, replacement string }{
+		{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"}, {"iveness", "ive"},
+		{"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"}, {"entli", "ent"}, {"ation", "ate"},
+		{"alism", "al"}, {"aliti", "al"}, {"ousli", "ous"}, {"iviti", "ive"}, {"fulli", "ful"},
+		{"enci", "ence"}, {"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"},
+		{"alli", "al"}, {"bli", "ble"}, {"ogi", "og"}, {"li", ""},
+	}
+	enStep3 = []struct{ suffix, replacement string }{
+		{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"},
+		{"ative", ""}, {"ical", "ic"}, {"ness", ""}, {"ful", ""},
+	}
+	enStep4 = []string{
+		"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent", "ism", "ate", "iti", "ous",
+		"ive", "ize", "ion", "al", "er", "ic",
+	}
+)
+
+func isEnglishVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+type enStemmer struct {
+	w      []byte
+	r1, r2 int
+}
+
+func (s *enStemmer) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(s.w), suffix)
+}
+
+func (s *enStemmer) replace(suffix, replacement string) {
+	s.w = append(s.w[:len(s.w)-len(suffix)], replacement...)
+}
+
+// containsVowel — есть ли гласная в первых n буквах
+func (s *enStemmer) containsVowel(n int) bool {
+	for _, c := range s.w[:n] {
+		if isEnglishVowel(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// shortSyllableAt — оканчивается ли на позиции end (не включая) короткий слог: согласная,
+// гласная и согласная кроме w, x, Y, либо гласная и согласная в начале слова
+func (s *enStemmer) shortSyllableAt(end int) bool {
+	w := s.w
+	if end == 2 {
+		return isEnglishVowel(w[0]) && !isEnglishVowel(w[1])
+	}
+	if end < 3 {
+		return false
+	}
+	c := w[end-1]
+	return !isEnglishVowel(w[end-3]) && isEnglishVowel(w[end-2]) && !isEnglishVowel(c) &&
+		c != 'w' && c != 'x' && c != 'Y'
+}
+
+func (s *enStemmer) isShort() bool {
+	return s.r1 >= len(s.w) && s.shortSyllableAt(len(s.w))
+}
+
+func stemEnglish(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	if stem, ok := enExceptions[word]; ok {
+		return stem
+	}
+
+	s := &enStemmer{w: []byte(strings.TrimPrefix(word, "'"))}
+	if len(s.w) == 0 {
+		return word
+	}
+	// «y» в начале слова и после гласной считается согласной
+	for i, c := range s.w {
+		if c == 'y' && (i == 0 || isEnglishVowel(s.w[i-1])) {
+			s.w[i] = 'Y'
+		}
+	}
+
+	region := func(from int) int {
+		for i := from + 1; i < len(s.w); i++ {
+			if !isEnglishVowel(s.w[i]) && isEnglishVowel(s.w[i-1]) {
+				return i + 1
+			}
+		}
+		return len(s.w)
+	}
+	s.r1 = region(0)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(s.w), prefix) {
+			s.r1 = len(prefix)
+		}
+	}
+	s.r2 = region(s.r1)
+
+	s.step0()
+	s.step1a()
+	if enInvariantAfter1a[string(s.w)] {
+		return strings.ToLower(string(s.w))
+	}
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return strings.ToLower(string(s.w))
+}
+
+func (s *enStemmer) step0() {
+	for _, suffix := range []string{"'s'", "'s", "'"} {
+		if s.hasSuffix(suffix) {
+			s.replace(suffix, "")
+			return
+		}
+	}
+}
+
+func (s *enStemmer) step1a() {
+	switch {
+	case s.hasSuffix("sses"):
+		s.replace("sses", "ss")
+	case s.hasSuffix("ied"), s.hasSuffix("ies"):
+		if len(s.w) > 4 {
+			s.replace(string(s.w[len(s.w)-3:]), "i")
+		} else {
+			s.replace(string(s.w[len(s.w)-3:]), "ie")
+		}
+	case s.hasSuffix("us"), s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		if len(s.w) > 2 && s.containsVowel(len(s.w)-2) {
+			s.replace("s", "")
+		}
+	}
+}
+
+func (s *enStemmer) step1b() {
+	for _, suffix := range []string{"eedly", "eed"} {
+		if s.hasSuffix(suffix) {
+			if len(s.w)-len(suffix) >= s.r1 {
+				s.replace(suffix, "ee")
+			}
+			return
+		}
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if !s.hasSuffix(suffix) {
+			continue
+		}
+		if !s.containsVowel(len(s.w) - len(suffix)) {
+			return
+		}
+		s.replace(suffix, "")
+		switch {
+		case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+			s.w = append(s.w, 'e')
+		case s.endsWithDouble():
+			s.w = s.w[:len(s.w)-1]
+		case s.isShort():
+			s.w = append(s.w, 'e')
+		}
+		return
+	}
+}
+
+func (s *enStemmer) endsWithDouble() bool {
+	n := len(s.w)
+	if n < 2 || s.w[n-1] != s.w[n-2] {
+		return false
+	}
+	return strings.IndexByte("bdfgmnprt", s.w[n-1]) >= 0
+}
+
+func (s *enStemmer) step1c() {
+	n := len(s.w)
+	if n > 2 && (s.w[n-1] == 'y' || s.w[n-1] == 'Y') && !isEnglishVowel(s.w[n-2]) {
+		s.w[n-1] = 'i'
+	}
+}
+
+func (s *enStemmer) step2() {
+	for _, rule := range enStep2 {
+		if !s.hasSuffix(rule.suffix) {
+			continue
+		}
+		if len(s.w)-len(rule.suffix) < s.r1 {
+			return
+		}
+		switch rule.suffix {
+		case "ogi":
+			if len(s.w) > 3 && s.w[len(s.w)-4] == 'l' {
+				s.replace(rule.suffix, rule.replacement)
+			}
+		case "li":
+			if len(s.w) > 2 && strings.IndexByte("cdeghkmnrt", s.w[len(s.w)-3]) >= 0 {
+				s.replace(rule.suffix, rule.replacement)
+			}
+		default:
+			s.replace(rule.suffix, rule.replacement)
+		}
+		return
+	}
+}
+
+func (s *enStemmer) step3() {
+	for _, rule := range enStep3 {
+		if !s.hasSuffix(rule.suffix) {
+			continue
+		}
+		if len(s.w)-len(rule.suffix) < s.r1 {
+			return
+		}
+		if rule.suffix == "ative" && len(s.w)-len(rule.suffix) < s.r2 {
+			return
+		}
+		s.replace(rule.suffix, rule.replacement)
+		return
+	}
+}
+
+func (s *enStemmer) step4() {
+	for _, suffix := range enStep4 {
+		if !s.hasSuffix(suffix) {
+			continue
+		}
+		start := len(s.w) - len(suffix)
+		if start < s.r2 {
+			return
+		}
+		if suffix == "ion" && (start == 0 || s.w[start-1] != 's' && s.w[start-1] != 't') {
+			return
+		}
+		s.replace(suffix, "")
+		return
+	}
+}
+
+func (s *enStemmer) step5() {
+	n := len(s.w)
+	switch {
+	case n > 0 && s.w[n-1] == 'e':
+		if n-1 >= s.r2 || n-1 >= s.r1 && !s.shortSyllableAt(n-1) {
+			s.w = s.w[:n-1]
+		}
+	case n > 1 && s.w[n-1] == 'l' && s.w[n-2] == 'l' && n-1 >= s.r2:
+		s.w = s.w[:n-1]
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+// Стеммер Snowball для русского языка (snowballstem.org/algorithms/russian/stemmer.html).
+// Окончания ищутся только в области RV, словообразовательный суффикс -ость — в R2.
+
+type ruSuffixGroup struct {
+	afterAYa bool // окончание отрезается, только если перед ним стоит «а» или «я»
+	suffixes []string
+}
+
+var (
+	ruPerfectiveGerund = []ruSuffixGroup{
+		{true, []string{"в", "вши", "вшись"}},
+		{false, []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}},
+	}
+	ruAdjective = []ruSuffixGroup{
+		{false, []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым",
+			"ом", "его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}},
+	}
+	ruParticiple = []ruSuffixGroup{
+		{true, []string{"ем", "нн", "вш", "ющ", "щ"}},
+		{false, []string{"ивш", "ывш", "ующ"}},
+	}
+	ruReflexive = []ruSuffixGroup{{false, []string{"ся", "сь"}}}
+	ruVerb      = []ruSuffixGroup{
+		{true, []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны",
+			"ть", "ешь", "нно"}},
+		{false, []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл",
+			"им", "ым", "ен", "ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть",
+			"ишь", "ую", "ю"}},
+	}
+	ruNoun = []ruSuffixGroup{
+		{false, []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией",
+			"ей", "ой", "ий", "й", "иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы",
+			"ь", "ию", "ью", "ю", "ия", "ья", "я"}},
+	}
+	ruDerivational = []ruSuffixGroup{{false, []string{"ост", "ость"}}}
+	ruSuperlative  = []ruSuffixGroup{{false, []string{"ейш", "ейше"}}}
+)
+
+func isRussianVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+	return false
+}
+
+// ruSuffix ищет самое длинное окончание из групп, целиком лежащее не левее limit, и
+// возвращает длину, которую нужно отрезать (0 — не найдено или не выполнено условие группы)
+func ruSuffix(word []rune, limit int, groups []ruSuffixGroup) int {
+	best, bestAfterAYa := 0, false
+	for _, g := range groups {
+		for _, s := range g.suffixes {
+			n := len([]rune(s))
+			if n <= best || len(word)-n < limit || string(word[len(word)-n:]) != s {
+				continue
+			}
+			best, bestAfterAYa = n, g.afterAYa
+		}
+	}
+	if best == 0 {
+		return 0
+	}
+	if bestAfterAYa {
+		i := len(word) - best - 1
+		if i < limit || word[i] != 'а' && word[i] != 'я' {
+			return 0
+		}
+	}
+	return best
+}
+
+func stemRussian(word string) string {
+	w := []rune(word)
+	for i, r := range w {
+		if r == 'ё' {
+			w[i] = 'е'
+		}
+	}
+
+	// RV — после первой гласной; R1 — после первой согласной, идущей за гласной; R2 — то же внутри R1
+	rv, r2 := len(w), len(w)
+	for i, r := range w {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	region := func(from int) int {
+		for i := from + 1; i < len(w); i++ {
+			if !isRussianVowel(w[i]) && isRussianVowel(w[i-1]) {
+				return i + 1
+			}
+		}
+		return len(w)
+	}
+	if r1 := region(0); r1 < len(w) {
+		r2 = region(r1)
+	}
+
+	cut := func(n int) { w = w[:len(w)-n] }
+
+	// Шаг 1
+	if n := ruSuffix(w, rv, ruPerfectiveGerund); n > 0 {
+		cut(n)
+	} else {
+		if n := ruSuffix(w, rv, ruReflexive); n > 0 {
+			cut(n)
+		}
+		if n := ruSuffix(w, rv, ruAdjective); n > 0 {
+			cut(n)
+			if n := ruSuffix(w, rv, ruParticiple); n > 0 {
+				cut(n)
+			}
+		} else if n := ruSuffix(w, rv, ruVerb); n > 0 {
+			cut(n)
+		} else if n := ruSuffix(w, rv, ruNoun); n > 0 {
+			cut(n)
+		}
+	}
+
+	// Шаг 2
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		cut(1)
+	}
+
+	// Шаг 3
+	if n := ruSuffix(w, r2, ruDerivational); n > 0 {
+		cut(n)
+	}
+
+	// Шаг 4
+	undoubleN := func() bool {
+		if len(w)-2 >= rv && w[len(w)-1] == 'н' && w[len(w)-2] == 'н' {
+			cut(1)
+			return true
+		}
+		return false
+	}
+	if n := ruSuffix(w, rv, ruSuperlative); n > 0 {
+		cut(n)
+		undoubleN()
+	} else if !undoubleN() && len(w) > rv && w[len(w)-1] == 'ь' {
+		cut(1)
+	}
+
+	return string(w)
+}
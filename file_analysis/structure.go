@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Порог оценки, с которого функция попадает в отчёт
+const structFunctionMinScore = 0.7
+
+// Формы поддеревьев меньше structMinSubtree узлов — общие идиомы (if err != nil,
+// присваивание результата вызова) и в отпечатки не попадают. Но и у неродственных программ
+// часть крупных форм совпадает, поэтому в оценку идёт только покрытие сверх structBaseline.
+// Калибровка на парах файлов 300 разных пакетов стандартной библиотеки: сырое покрытие —
+// медиана 0.06, 99-й перцентиль 0.29; после вычета базы 99-й перцентиль — 0.16
+// (см. TestStructureUnrelatedPrograms). Программа, разбитая на вспомогательные функции,
+// получает 0.86-0.89.
+const (
+	structMinSubtree = 10
+	structBaseline   = 0.15
+)
+
+// FunctionShape — структурные отпечатки одной функции: хеши форм её поддеревьев
+// с нормализованными именами и значениями литералов
+type FunctionShape struct {
+	Name      string   `json:"name"`
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	Hashes    []uint64 `json:"hashes"`
+}
+
+// structureRecord — структура Go-сдачи, хранится в StructureDir/{work_id}/{submission_id}.json
+type structureRecord struct {
+	SubmissionID string          `json:"submission_id"`
+	Sender       string          `json:"sender"`
+	WorkID       string          `json:"work_id"`
+	FileName     string          `json:"file_name"`
+	Timestamp    string          `json:"timestamp"`
+	Functions    []FunctionShape `json:"functions"`
+}
+
+type FunctionMatch struct {
+	Name           string  `json:"name"`
+	StartLine      int     `json:"start_line"`
+	EndLine        int     `json:"end_line"`
+	OtherName      string  `json:"other_name"`
+	OtherStartLine int     `json:"other_start_line"`
+	OtherEndLine   int     `json:"other_end_line"`
+	Score          float64 `json:"score"`
+}
+
+// StructuralMatch — структурная схожесть с другой сдачей (см. compareStructures)
+type StructuralMatch struct {
+	ID        string          `json:"id"`
+	FileName  string          `json:"file_name"`
+	Sender    string          `json:"sender"`
+	Score     float64         `json:"score"`
+	Functions []FunctionMatch `json:"functions,omitempty"`
+}
+
+// Коммутативные операции: порядок операндов не влияет на форму
+var commutativeOps = map[token.Token]bool{
+	token.ADD: true, token.MUL: true, token.EQL: true, token.NEQ: true,
+	token.LAND: true, token.LOR: true, token.AND: true, token.OR: true, token.XOR: true,
+}
+
+// goFunctionShapes разбирает Go-файл и строит отпечатки поддеревьев для каждой функции
+func goFunctionShapes(src string) ([]FunctionShape, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go source: %w", err)
+	}
+
+	var shapes []FunctionShape
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		shapes = append(shapes, FunctionShape{
+			Name:      funcName(fn),
+			StartLine: fset.Position(fn.Pos()).Line,
+			EndLine:   fset.Position(fn.End()).Line,
+			Hashes:    subtreeHashes(fn.Body),
+		})
+	}
+	return shapes, nil
+}
+
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// Глубина форм: поддерево хешируется не целиком, а срезом до structDepth уровней,
+// поэтому локальная правка (другой вид цикла, лишняя проверка) меняет лишь несколько форм
+const structDepth = 3
+
+type shapeHash [structDepth]uint64
+
+type shapeFrame struct {
+	node     ast.Node
+	children []shapeHash
+	size     int
+}
+
+// subtreeHashes обходит дерево в обратном порядке и для каждого узла с поддеревом не меньше
+// structMinSubtree узлов хеширует его форму глубиной 2 и 3: тип узла, оператор и формы детей.
+// Имена (кроме встроенных) и значения литералов отбрасываются, for и range считаются одним
+// видом цикла, i++ — то же, что i += 1, скобки и порядок операндов коммутативных операций
+// не важны.
+func subtreeHashes(root ast.Node) []uint64 {
+	var hashes []uint64
+	stack := []shapeFrame{{}}
+
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil {
+			frame := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			parent := &stack[len(stack)-1]
+
+			if _, ok := frame.node.(*ast.ParenExpr); ok && len(frame.children) == 1 {
+				parent.children = append(parent.children, frame.children[0])
+				parent.size += frame.size
+				return true
+			}
+
+			label := shapeLabel(frame.node)
+			commutative := false
+			if bin, ok := frame.node.(*ast.BinaryExpr); ok {
+				commutative = commutativeOps[bin.Op]
+			}
+
+			var shape shapeHash
+			for depth := range shape {
+				level := make([]uint64, 0, len(frame.children))
+				if depth > 0 {
+					for _, c := range frame.children {
+						level = append(level, c[depth-1])
+					}
+				}
+				if commutative {
+					sort.Slice(level, func(i, j int) bool { return level[i] < level[j] })
+				}
+				shape[depth] = hashShape(label, level)
+			}
+
+			size := frame.size + 1
+			if len(frame.children) > 0 && size >= structMinSubtree {
+				hashes = append(hashes, shape[1:]...)
+			}
+			parent.children = append(parent.children, shape)
+			parent.size += size
+			return true
+		}
+
+		if _, ok := n.(*ast.CommentGroup); ok {
+			return false
+		}
+		stack = append(stack, shapeFrame{node: n})
+		return true
+	})
+	return hashes
+}
+
+func hashShape(label string, children []uint64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(label))
+	var buf [8]byte
+	for _, c := range children {
+		binary.LittleEndian.PutUint64(buf[:], c)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+func shapeLabel(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Ident:
+		if types.Universe.Lookup(n.Name) != nil {
+			return "builtin:" + n.Name
+		}
+		return "ident"
+	case *ast.BasicLit:
+		return "lit:" + n.Kind.String()
+	case *ast.BinaryExpr:
+		return "binary:" + n.Op.String()
+	case *ast.UnaryExpr:
+		return "unary:" + n.Op.String()
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			return "assign:="
+		}
+		return "assign:" + n.Tok.String()
+	case *ast.IncDecStmt:
+		if n.Tok == token.INC {
+			return "assign:+="
+		}
+		return "assign:-="
+	case *ast.ForStmt, *ast.RangeStmt:
+		return "loop"
+	case *ast.BranchStmt:
+		return "branch:" + n.Tok.String()
+	}
+	return strings.TrimPrefix(reflect.TypeOf(n).String(), "*ast.")
+}
+
+// Хеши как мультимножество: одинаковые поддеревья учитываются столько раз, сколько встречаются
+func hashCounts(hashes []uint64) map[uint64]int {
+	counts := make(map[uint64]int, len(hashes))
+	for _, h := range hashes {
+		counts[h]++
+	}
+	return counts
+}
+
+func multisetIntersection(a, b map[uint64]int) int {
+	n := 0
+	for h, c := range a {
+		n += min(c, b[h])
+	}
+	return n
+}
+
+// structCoverage — доля форм a, найденных в b, сверх structBaseline, пересчитанная в [0, 1]
+func structCoverage(a []uint64, b map[uint64]int) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	coverage := float64(multisetIntersection(hashCounts(a), b)) / float64(len(a))
+	return max(0, coverage-structBaseline) / (1 - structBaseline)
+}
+
+// compareStructures оценивает, какая доля форм проверяемой программы найдена где-либо в другой.
+// Формы сравниваются без учёта границ функций, поэтому разбиение функции на вспомогательные,
+// слияние функций и их перестановка оценку почти не меняют. В отчёт попадают функции, покрытые
+// не меньше чем на structFunctionMinScore, с функцией другой программы, где совпало больше всего.
+func compareStructures(mine, other []FunctionShape) (float64, []FunctionMatch) {
+	var mineAll, otherAll []uint64
+	for _, f := range mine {
+		mineAll = append(mineAll, f.Hashes...)
+	}
+	for _, o := range other {
+		otherAll = append(otherAll, o.Hashes...)
+	}
+	otherCounts := hashCounts(otherAll)
+	score := structCoverage(mineAll, otherCounts)
+	if score == 0 {
+		return 0, nil
+	}
+
+	var functions []FunctionMatch
+	for _, f := range mine {
+		fScore := structCoverage(f.Hashes, otherCounts)
+		if fScore < structFunctionMinScore {
+			continue
+		}
+		counts := hashCounts(f.Hashes)
+		best, bestShared := 0, -1
+		for i, o := range other {
+			if shared := multisetIntersection(counts, hashCounts(o.Hashes)); shared > bestShared {
+				best, bestShared = i, shared
+			}
+		}
+		o := other[best]
+		functions = append(functions, FunctionMatch{
+			Name:           f.Name,
+			StartLine:      f.StartLine,
+			EndLine:        f.EndLine,
+			OtherName:      o.Name,
+			OtherStartLine: o.StartLine,
+			OtherEndLine:   o.EndLine,
+			Score:          fScore,
+		})
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Score > functions[j].Score })
+	return score, functions
+}
+
+// findStructuralMatches сравнивает структуру сдачи с остальными Go-сдачами задания (кроме своих)
+func findStructuralMatches(rec structureRecord, limit int) ([]StructuralMatch, error) {
+	var others []structureRecord
+	err := loadWorkRecords(config.StructureDir, rec.WorkID, func(data []byte) error {
+		var other structureRecord
+		if err := json.Unmarshal(data, &other); err != nil {
+			return err
+		}
+		others = append(others, other)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []StructuralMatch
+	for _, other := range others {
+		if other.SubmissionID == rec.SubmissionID || other.Sender == rec.Sender {
+			continue
+		}
+
+		score, functions := compareStructures(rec.Functions, other.Functions)
+		if score == 0 {
+			continue
+		}
+		matches = append(matches, StructuralMatch{
+			ID:        other.SubmissionID,
+			FileName:  other.FileName,
+			Sender:    other.Sender,
+			Score:     score,
+			Functions: functions,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Одна и та же программа (частотный словарь) в трёх вариантах и неродственная программа
+const wordCountProgram = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type entry struct {
+	word  string
+	count int
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("usage: wc file")
+		os.Exit(1)
+	}
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.ToLower(scanner.Text())
+		for _, w := range strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == ',' || r == '.' }) {
+			if len(w) < 3 {
+				continue
+			}
+			counts[w]++
+		}
+	}
+
+	var entries []entry
+	for w, c := range counts {
+		entries = append(entries, entry{w, c})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].word < entries[j].word
+	})
+
+	total := 0
+	for _, e := range entries {
+		total += e.count
+	}
+	for i := 0; i < 10 && i < len(entries); i++ {
+		share := float64(entries[i].count) / float64(total) * 100
+		fmt.Printf("%2d. %-15s %5d %6.2f%%\n", i+1, entries[i].word, entries[i].count, share)
+	}
+}
+`
+
+// wordCountProgram, разбитая на вспомогательные функции, с другими именами
+const wordCountSplitProgram = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type item struct {
+	text string
+	n    int
+}
+
+func isSeparator(r rune) bool { return r == ' ' || r == ',' || r == '.' }
+
+func countWords(in io.Reader) map[string]int {
+	result := make(map[string]int)
+	sc := bufio.NewScanner(in)
+	for sc.Scan() {
+		text := strings.ToLower(sc.Text())
+		for _, token := range strings.FieldsFunc(text, isSeparator) {
+			if len(token) < 3 {
+				continue
+			}
+			result[token]++
+		}
+	}
+	return result
+}
+
+func sortedItems(result map[string]int) []item {
+	var items []item
+	for t, n := range result {
+		items = append(items, item{t, n})
+	}
+	sort.Slice(items, func(a, b int) bool {
+		if items[a].n != items[b].n {
+			return items[a].n > items[b].n
+		}
+		return items[a].text < items[b].text
+	})
+	return items
+}
+
+func printTop(items []item, limit int) {
+	sum := 0
+	for _, it := range items {
+		sum += it.n
+	}
+	for k := 0; k < limit && k < len(items); k++ {
+		pct := float64(items[k].n) / float64(sum) * 100
+		fmt.Printf("%2d. %-15s %5d %6.2f%%\n", k+1, items[k].text, items[k].n, pct)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("usage: wc file")
+		os.Exit(1)
+	}
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	printTop(sortedItems(countWords(file)), 10)
+}
+`
+
+// wordCountSplitProgram с переставленными функциями, другими именами и мелкими правками
+const wordCountReorderedProgram = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type pair struct {
+	key   string
+	times int
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("usage: wc file")
+		os.Exit(1)
+	}
+	in, e := os.Open(os.Args[1])
+	if e != nil {
+		fmt.Println(e)
+		os.Exit(1)
+	}
+	defer in.Close()
+
+	report(rank(tally(in)), 10)
+}
+
+func report(pairs []pair, top int) {
+	all := 0
+	for i := range pairs {
+		all += pairs[i].times
+	}
+	for i := 0; i < top && i < len(pairs); i++ {
+		percent := float64(pairs[i].times) / float64(all) * 100
+		fmt.Printf("%2d. %-15s %5d %6.2f%%\n", i+1, pairs[i].key, pairs[i].times, percent)
+	}
+}
+
+func rank(freq map[string]int) []pair {
+	var pairs []pair
+	for key, times := range freq {
+		pairs = append(pairs, pair{key, times})
+	}
+	sort.Slice(pairs, func(x, y int) bool {
+		if pairs[x].times != pairs[y].times {
+			return pairs[x].times > pairs[y].times
+		}
+		return pairs[x].key < pairs[y].key
+	})
+	return pairs
+}
+
+func tally(r io.Reader) map[string]int {
+	freq := make(map[string]int)
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		s := strings.ToLower(lines.Text())
+		for _, word := range strings.FieldsFunc(s, separator) {
+			if len(word) < 3 {
+				continue
+			}
+			freq[word] += 1
+		}
+	}
+	return freq
+}
+
+func separator(c rune) bool { return '.' == c || c == ',' || c == ' ' }
+`
+
+const bankProgram = `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Account struct {
+	Owner   string
+	Balance float64
+	History []float64
+}
+
+var ErrInsufficient = errors.New("insufficient funds")
+
+func (a *Account) Deposit(amount float64) error {
+	if amount <= 0 {
+		return fmt.Errorf("invalid amount %.2f", amount)
+	}
+	a.Balance += amount
+	a.History = append(a.History, amount)
+	return nil
+}
+
+func (a *Account) Withdraw(amount float64) error {
+	if amount > a.Balance {
+		return ErrInsufficient
+	}
+	a.Balance -= amount
+	a.History = append(a.History, -amount)
+	return nil
+}
+
+func Transfer(from, to *Account, amount float64) error {
+	if err := from.Withdraw(amount); err != nil {
+		return fmt.Errorf("transfer from %s: %w", from.Owner, err)
+	}
+	return to.Deposit(amount)
+}
+
+func main() {
+	alice := &Account{Owner: "alice", Balance: 100}
+	bob := &Account{Owner: "bob"}
+	for _, amount := range []float64{30, 50, 40} {
+		if err := Transfer(alice, bob, amount); err != nil {
+			fmt.Println("error:", err)
+			continue
+		}
+		fmt.Printf("%s: %.2f, %s: %.2f\n", alice.Owner, alice.Balance, bob.Owner, bob.Balance)
+	}
+}
+`
+
+func mustShapes(t *testing.T, src string) []FunctionShape {
+	t.Helper()
+	shapes, err := goFunctionShapes(src)
+	if err != nil {
+		t.Fatalf("goFunctionShapes: %v", err)
+	}
+	return shapes
+}
+
+func TestCompareStructures(t *testing.T) {
+	tests := []struct {
+		name      string
+		mine      string
+		other     string
+		minScore  float64
+		maxScore  float64
+		wantFuncs bool
+	}{
+		{"identical", wordCountProgram, wordCountProgram, 1, 1, true},
+		{"split into helpers", wordCountProgram, wordCountSplitProgram, 0.7, 1, true},
+		{"helpers merged", wordCountSplitProgram, wordCountProgram, 0.7, 1, true},
+		{"reordered", wordCountSplitProgram, wordCountReorderedProgram, 0.7, 1, true},
+		{"reordered and merged", wordCountReorderedProgram, wordCountProgram, 0.7, 1, true},
+		{"unrelated", wordCountProgram, bankProgram, 0, 0.1, false},
+		{"unrelated reverse", bankProgram, wordCountSplitProgram, 0, 0.1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, functions := compareStructures(mustShapes(t, tt.mine), mustShapes(t, tt.other))
+			if score < tt.minScore || score > tt.maxScore {
+				t.Errorf("score %.3f, want between %.2f and %.2f", score, tt.minScore, tt.maxScore)
+			}
+			if (len(functions) > 0) != tt.wantFuncs {
+				t.Errorf("functions %+v, want matches = %v", functions, tt.wantFuncs)
+			}
+		})
+	}
+}
+
+// Разбитая на части функция сопоставляется с той вспомогательной функцией, где совпало больше всего
+func TestCompareStructuresSplitFunction(t *testing.T) {
+	_, functions := compareStructures(mustShapes(t, wordCountProgram), mustShapes(t, wordCountSplitProgram))
+	if len(functions) != 1 || functions[0].Name != "main" {
+		t.Fatalf("functions %+v, want main", functions)
+	}
+	if functions[0].OtherName != "countWords" && functions[0].OtherName != "sortedItems" && functions[0].OtherName != "printTop" {
+		t.Errorf("main matched %s, want one of its helpers", functions[0].OtherName)
+	}
+}
+
+// TestStructureUnrelatedPrograms проверяет калибровку structBaseline на файлах разных пакетов
+// стандартной библиотеки: неродственные программы не должны доходить до порога подозрения
+func TestStructureUnrelatedPrograms(t *testing.T) {
+	root := filepath.Join(runtime.GOROOT(), "src")
+	var programs [][]FunctionShape
+	seen := make(map[string]bool)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if len(programs) >= 300 {
+			return filepath.SkipAll
+		}
+		if err != nil || info.IsDir() || seen[filepath.Dir(path)] || strings.Contains(path, "testdata") ||
+			!strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") ||
+			info.Size() < 2000 || info.Size() > 8000 {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		if shapes, err := goFunctionShapes(string(data)); err == nil && len(shapes) > 0 {
+			seen[filepath.Dir(path)] = true
+			programs = append(programs, shapes)
+		}
+		return nil
+	})
+	if len(programs) < 100 {
+		t.Skipf("only %d Go files found in %s", len(programs), root)
+	}
+
+	var scores []float64
+	for i, a := range programs {
+		for j, b := range programs {
+			if i != j {
+				score, _ := compareStructures(a, b)
+				scores = append(scores, score)
+			}
+		}
+	}
+	sort.Float64s(scores)
+	median, p99 := scores[len(scores)/2], scores[len(scores)*99/100]
+	t.Logf("%d pairs: median %.3f, p99 %.3f", len(scores), median, p99)
+	if median > 0.05 || p99 > 0.2 {
+		t.Errorf("unrelated programs: median %.3f, p99 %.3f; want at most 0.05 and 0.2", median, p99)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var submissionIDPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// Submission — проанализированный текст сдачи; смещения в отчётах относятся к нему
+type Submission struct {
+	ID        string  `json:"id"`
+	Sender    string  `json:"sender"`
+	WorkID    string  `json:"work_id"`
+	FileName  string  `json:"file_name"`
+	Timestamp string  `json:"timestamp"`
+	Text      string  `json:"text"`
+	Blocks    []Block `json:"blocks,omitempty"`
+	// Строки кода блокнота по ячейкам
+	CodeBlocks []Block `json:"code_blocks,omitempty"`
+}
+
+func saveSubmission(sub Submission) error {
+	if err := os.MkdirAll(config.TextDir, 0755); err != nil {
+		return fmt.Errorf("failed to create text directory: %w", err)
+	}
+
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal submission: %w", err)
+	}
+
+	path := filepath.Join(config.TextDir, sub.ID+".json")
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write submission text: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadSubmission(id string) (Submission, error) {
+	if !submissionIDPattern.MatchString(id) {
+		return Submission{}, fmt.Errorf("invalid submission id %q", id)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(config.TextDir, id+".json"))
+	if err != nil {
+		return Submission{}, err
+	}
+
+	var sub Submission
+	if err := json.Unmarshal(data, &sub); err != nil {
+		return Submission{}, fmt.Errorf("failed to parse submission %s: %w", id, err)
+	}
+	return sub, nil
+}
@@ -3,42 +3,174 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 )
 
-// Локальный микросервис embeddings генерирует 384-мерные эмбединги
-// через sentence-transformers/all-MiniLM-L6-v2
-const embeddingsServiceURL = "http://embeddings:8003"
+// errEmbeddingRejected — сервис ответил, но отклонил запрос или вернул негодный ответ. В отличие
+// от сетевых ошибок и таймаутов такой сбой может зависеть от текстов запроса.
+var errEmbeddingRejected = errors.New("embeddings service rejected the request")
 
-func generateVector(text string) ([]float32, error) {
-	url := embeddingsServiceURL + "/embed"
+// EmbeddingProvider превращает пакет текстов в векторы размерности Dimension(), по вектору
+// на текст в том же порядке
+type EmbeddingProvider interface {
+	Embed(texts []string) ([][]float32, error)
+	Model() string
+	Dimension() int
+}
+
+// embedder выбирается в main по EMBEDDING_PROVIDER; запросы к нему собирает в пакеты embeddings
+var (
+	embedder   EmbeddingProvider
+	embeddings *EmbeddingBatcher
+)
 
-	payload := map[string]interface{}{
-		"text": text,
+// newEmbeddingProvider: "embed" — локальный микросервис embeddings (POST /embed/batch),
+// "openai" — любой OpenAI-совместимый сервер (POST /v1/embeddings)
+func newEmbeddingProvider(c Config) (EmbeddingProvider, error) {
+	base := strings.TrimSuffix(c.EmbeddingURL, "/")
+	client := &http.Client{Timeout: 60 * time.Second}
+	switch c.EmbeddingProvider {
+	case "embed":
+		return &embedService{url: base + "/embed/batch", model: c.EmbeddingModel, dim: c.EmbeddingDim, client: client}, nil
+	case "openai":
+		return &openAIEmbeddings{
+			url:    base + "/v1/embeddings",
+			model:  c.EmbeddingModel,
+			dim:    c.EmbeddingDim,
+			apiKey: c.EmbeddingAPIKey,
+			client: client,
+		}, nil
 	}
+	return nil, fmt.Errorf("unknown embedding provider %q", c.EmbeddingProvider)
+}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+// embeddingModelID — модель, которой посчитаны векторы; от неё зависит кэш эмбеддингов
+func embeddingModelID(c Config) string {
+	return fmt.Sprintf("%s %s %d", c.EmbeddingProvider, c.EmbeddingModel, c.EmbeddingDim)
+}
+
+// generateVectors возвращает эмбеддинги текстов. Векторы уже встречавшихся текстов берутся
+// из кэша, остальные отправляются пакетами вместе с текстами других одновременно идущих анализов.
+func generateVectors(texts []string) ([][]float32, error) {
+	kind := embeddingCacheKind(embeddingModelID(config))
+	vectors := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	var missing []int
+	var missingTexts []string
+	for i, text := range texts {
+		keys[i] = cacheKey(text)
+		if !cache.Get(kind, keys[i], &vectors[i]) || len(vectors[i]) != embedder.Dimension() {
+			missing = append(missing, i)
+			missingTexts = append(missingTexts, text)
+		}
+	}
+	if len(missing) == 0 {
+		return vectors, nil
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	computed, err := embeddings.Embed(missingTexts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+	for j, i := range missing {
+		vectors[i] = computed[j]
+		cache.Put(kind, keys[i], computed[j])
 	}
+	return vectors, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
+// embedService — микросервис embeddings: {"texts": [...]} → {"embeddings": [[...], ...]}.
+// Модель задаётся в самом сервисе, здесь её имя только для отчётов и логов.
+type embedService struct {
+	url    string
+	model  string
+	dim    int
+	client *http.Client
+}
 
-	client := &http.Client{Timeout: 60 * time.Second}
+func (s *embedService) Model() string  { return s.model }
+func (s *embedService) Dimension() int { return s.dim }
+
+func (s *embedService) Embed(texts []string) ([][]float32, error) {
+	var result struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	payload := map[string]interface{}{"texts": texts}
+	if err := postEmbedding(s.client, s.url, nil, payload, &result); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(result.Embeddings))
+	for i, e := range result.Embeddings {
+		vectors[i] = toFloat32(e)
+	}
+	return vectors, nil
+}
+
+// openAIEmbeddings — OpenAI-совместимый эндпоинт /v1/embeddings (OpenAI, vLLM, Ollama,
+// text-embeddings-inference или /v1/embeddings сервиса embeddings)
+type openAIEmbeddings struct {
+	url    string
+	model  string
+	dim    int
+	apiKey string
+	client *http.Client
+}
+
+func (o *openAIEmbeddings) Model() string  { return o.model }
+func (o *openAIEmbeddings) Dimension() int { return o.dim }
+
+func (o *openAIEmbeddings) Embed(texts []string) ([][]float32, error) {
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	payload := map[string]interface{}{"model": o.model, "input": texts}
+	var headers map[string]string
+	if o.apiKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + o.apiKey}
+	}
+	if err := postEmbedding(o.client, o.url, headers, payload, &result); err != nil {
+		return nil, err
+	}
+	// Порядок векторов в ответе определяет поле index
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("%w: invalid index %d", errEmbeddingRejected, d.Index)
+		}
+		vectors[d.Index] = toFloat32(d.Embedding)
+	}
+	return vectors, nil
+}
+
+// postEmbedding отправляет запрос к сервису эмбеддингов с тремя попытками при сетевых ошибках
+func postEmbedding(client *http.Client, url string, headers map[string]string, payload, out interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
 
 	var resp *http.Response
 	maxRetries := 3
 	var lastErr error
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
 		resp, err = client.Do(req)
 		if err == nil {
 			break
@@ -47,38 +179,30 @@ func generateVector(text string) ([]float32, error) {
 		time.Sleep(time.Duration(attempt+1) * time.Second)
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("request to embeddings service failed after %d attempts: %w", maxRetries, lastErr)
+	if resp == nil {
+		return fmt.Errorf("request to embeddings service failed after %d attempts: %w", maxRetries, lastErr)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("embeddings service error [%d]: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("%w [%d]: %s", errEmbeddingRejected, resp.StatusCode, string(body))
 	}
 
-	// Парсим ответ: {"embedding": [0.1, 0.2, ..., 0.3]}
-	var result struct {
-		Embedding []float64 `json:"embedding"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse embedding response: %w", err)
-	}
-
-	if len(result.Embedding) != 384 {
-		return nil, fmt.Errorf("unexpected embedding dimension: got %d, expected 384", len(result.Embedding))
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%w: failed to parse response: %v", errEmbeddingRejected, err)
 	}
+	return nil
+}
 
-	// Конвертируем в float32
-	vector := make([]float32, len(result.Embedding))
-	for i, v := range result.Embedding {
+func toFloat32(values []float64) []float32 {
+	vector := make([]float32, len(values))
+	for i, v := range values {
 		vector[i] = float32(v)
 	}
-
-	return vector, nil
+	return vector
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// VectorPoint — точка хранилища: именованные векторы и плоский payload
+type VectorPoint struct {
+	ID      int64                  `json:"id"`
+	Vectors map[string][]float32   `json:"vectors"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// VectorFilter — условия на поля payload: все Must совпадают и ни одно MustNot не совпадает
+type VectorFilter struct {
+	Must    map[string]interface{}
+	MustNot map[string]interface{}
+}
+
+// VectorQuery — поиск ближайших точек по вектору с именем Vector (косинусная близость)
+type VectorQuery struct {
+	Vector   string
+	Values   []float32
+	Limit    int
+	MinScore float64
+	Filter   VectorFilter
+}
+
+type ScoredPoint struct {
+	ID      int64
+	Score   float64
+	Payload map[string]interface{}
+}
+
+// VectorStore — хранилище векторов фрагментов. Qdrant — основной вариант; для небольших
+// установок и проверок без Qdrant есть полный перебор в памяти с журналом на диске.
+type VectorStore interface {
+	// Init создаёт хранилище с именованными векторами заданных размеров или проверяет существующее
+	Init(vectors map[string]int) error
+	// Reset удаляет все точки и создаёт хранилище заново с заданными векторами (для reindex)
+	Reset(vectors map[string]int) error
+	Upsert(points []VectorPoint) error
+	// Search выполняет пакет запросов; ответы идут в порядке запросов, точки — по убыванию близости
+	Search(queries []VectorQuery) ([][]ScoredPoint, error)
+	Delete(filter VectorFilter) error
+	// Scroll обходит все точки, подходящие под фильтр, пока fn не вернёт ошибку
+	Scroll(filter VectorFilter, fn func(VectorPoint) error) error
+}
+
+var vectorStore VectorStore
+
+// newVectorStore: VECTOR_STORE=qdrant (по умолчанию) или memory
+func newVectorStore(c Config) (VectorStore, error) {
+	switch c.VectorStore {
+	case "qdrant":
+		return newQdrantStore(c.QdrantURL, c.CollectionName), nil
+	case "memory":
+		return openMemoryStore(c.VectorStorePath)
+	}
+	return nil, fmt.Errorf("unknown vector store %q", c.VectorStore)
+}
+
+// collectionVectors — именованные векторы точек и их размеры
+func collectionVectors() map[string]int {
+	return map[string]int{
+		modelVectorName:   embedder.Dimension(),
+		hashingVectorName: config.FallbackDim,
+	}
+}
+
+// jsonValue приводит значение к виду, в котором оно возвращается из JSON (числа — float64),
+// чтобы payload из памяти и из Qdrant сравнивались и читались одинаково
+func jsonValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func payloadString(payload map[string]interface{}, key string) string {
+	s, _ := payload[key].(string)
+	return s
+}
+
+func payloadInt(payload map[string]interface{}, key string) int {
+	f, _ := payload[key].(float64)
+	return int(f)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+type Verdict string
+
+const (
+	VerdictClean       Verdict = "clean"
+	VerdictSuspicious  Verdict = "suspicious"
+	VerdictPlagiarized Verdict = "plagiarized"
+)
+
+// Thresholds — пороги, по которым вынесен вердикт; сохраняются в отчёте для аудита. Полосы
+// других шкал в отчёте — те, что применялись: пороги задания сдвигают их так же, как основные.
+type Thresholds struct {
+	Suspicious  float64 `json:"suspicious"`
+	Plagiarized float64 `json:"plagiarized"`
+	SearchLimit int     `json:"search_limit"`
+	Source      string  `json:"source"`
+	// Пороги для косинуса запасного хеширующего вектора: его шкала ниже, чем у модели
+	Fallback *Band `json:"fallback,omitempty"`
+	// Пороги для доли отпечатков кода и структурной оценки
+	Code *Band `json:"code,omitempty"`
+}
+
+// Band — пороги полос для оценок другой шкалы
+type Band struct {
+	Suspicious  float64 `json:"suspicious"`
+	Plagiarized float64 `json:"plagiarized"`
+}
+
+// resolveThresholds берёт глобальные пороги и переопределяет их настройками задания
+func resolveThresholds(s *WorkSettings) Thresholds {
+	t := Thresholds{
+		Suspicious:  config.SuspiciousThreshold,
+		Plagiarized: config.SimilarityThreshold,
+		SearchLimit: config.SearchLimit,
+		Source:      "global",
+	}
+
+	// Source — "mixed", если задание переопределяет только один порог. Согласованность порогов
+	// задания gateway проверяет при сохранении, но задания, сохранённые раньше, или расхождение
+	// глобальных порогов gateway и file_analysis могут дать "подозрительно" выше "плагиата" —
+	// тогда пороги задания не применяются.
+	if s != nil && s.consistentThresholds() {
+		if s.SuspiciousThreshold > 0 {
+			t.Suspicious = s.SuspiciousThreshold
+		}
+		if s.SimilarityThreshold > 0 {
+			t.Plagiarized = s.SimilarityThreshold
+		}
+		switch {
+		case s.SuspiciousThreshold > 0 && s.SimilarityThreshold > 0:
+			t.Source = "work"
+		case s.SuspiciousThreshold > 0 || s.SimilarityThreshold > 0:
+			t.Source = "mixed"
+		}
+	}
+	t.deriveBands()
+	return t
+}
+
+// checkThresholdConfig проверяет глобальные полосы: порог "подозрительно" не выше порога "плагиат"
+func checkThresholdConfig() error {
+	bands := []struct {
+		name                    string
+		suspicious, plagiarized float64
+	}{
+		{"SUSPICIOUS_THRESHOLD/SIMILARITY_THRESHOLD", config.SuspiciousThreshold, config.SimilarityThreshold},
+		{"FALLBACK_SUSPICIOUS_THRESHOLD/FALLBACK_SIMILARITY_THRESHOLD", config.FallbackSuspicious, config.FallbackPlagiarized},
+		{"CODE_SUSPICIOUS_THRESHOLD/CODE_SIMILARITY_THRESHOLD", config.CodeSuspicious, config.CodePlagiarized},
+	}
+	for _, b := range bands {
+		if b.suspicious > b.plagiarized {
+			return fmt.Errorf("%s: %v exceeds %v", b.name, b.suspicious, b.plagiarized)
+		}
+	}
+	return nil
+}
+
+// consistentThresholds — не начинается ли с порогами задания полоса "подозрительно" выше "плагиата"
+func (s *WorkSettings) consistentThresholds() bool {
+	suspicious, plagiarized := config.SuspiciousThreshold, config.SimilarityThreshold
+	if s.SuspiciousThreshold > 0 {
+		suspicious = s.SuspiciousThreshold
+	}
+	if s.SimilarityThreshold > 0 {
+		plagiarized = s.SimilarityThreshold
+	}
+	if suspicious > plagiarized {
+		log.Printf("Ignoring inconsistent work thresholds: suspicious %v exceeds plagiarized %v", suspicious, plagiarized)
+		return false
+	}
+	return true
+}
+
+// deriveBands заполняет незаданные полосы запасного вектора и кода: глобальные пороги шкалы,
+// умноженные на отношение основных порогов к глобальным. Задание, поднявшее порог плагиата
+// с 0.85 до 0.9, так же поднимает его и для кода, и для запасного вектора.
+func (t *Thresholds) deriveBands() {
+	scale := func(global, base, value float64) float64 {
+		if base <= 0 {
+			return global
+		}
+		return min(1, global*value/base)
+	}
+	band := func(suspicious, plagiarized float64) *Band {
+		return &Band{
+			Suspicious:  scale(suspicious, config.SuspiciousThreshold, t.Suspicious),
+			Plagiarized: scale(plagiarized, config.SimilarityThreshold, t.Plagiarized),
+		}
+	}
+	if t.Fallback == nil {
+		t.Fallback = band(config.FallbackSuspicious, config.FallbackPlagiarized)
+	}
+	if t.Code == nil {
+		t.Code = band(config.CodeSuspicious, config.CodePlagiarized)
+	}
+}
+
+func (t Thresholds) verdict(score float64) Verdict {
+	switch {
+	case score >= t.Plagiarized:
+		return VerdictPlagiarized
+	case score >= t.Suspicious:
+		return VerdictSuspicious
+	default:
+		return VerdictClean
+	}
+}
+
+// withBand — те же пороги, но с полосами b
+func (t Thresholds) withBand(b *Band) Thresholds {
+	if b != nil {
+		t.Suspicious, t.Plagiarized = b.Suspicious, b.Plagiarized
+	}
+	return t
+}
+
+// forEmbedding — пороги для косинуса векторов method
+func (t Thresholds) forEmbedding(method string) Thresholds {
+	if method == hashingVectorName {
+		return t.withBand(t.Fallback)
+	}
+	return t
+}
+
+var verdictRank = map[Verdict]int{VerdictClean: 0, VerdictSuspicious: 1, VerdictPlagiarized: 2}
+
+func worseVerdict(a, b Verdict) Verdict {
+	if verdictRank[b] > verdictRank[a] {
+		return b
+	}
+	return a
+}
+
+// scoreReport выносит вердикт по оценкам анализаторов. Оценки разных шкал сравниваются
+// каждая со своими порогами, вердикт — худший из полученных, similarity — наибольшая оценка.
+func scoreReport(report *Report, t Thresholds) {
+	embedding := embeddingSimilarity(report.Matches)
+	similarity := embedding
+	verdict := t.forEmbedding(report.EmbeddingMethod).verdict(embedding)
+
+	for _, m := range report.Matches {
+		similarity = max(similarity, m.Jaccard)
+		verdict = worseVerdict(verdict, t.verdict(m.Jaccard))
+	}
+
+	code := max(report.CodeOverlap, report.StructuralScore)
+	similarity = max(similarity, code)
+	verdict = worseVerdict(verdict, t.withBand(t.Code).verdict(code))
+
+	report.Similarity = similarity
+	report.Verdict = verdict
+	report.Plagiarized = verdict == VerdictPlagiarized
+	flagObfuscation(report)
+}
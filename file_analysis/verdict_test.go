@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func withThresholdConfig(t *testing.T) {
+	t.Helper()
+	saved := config
+	config.SuspiciousThreshold, config.SimilarityThreshold = 0.7, 0.85
+	config.FallbackSuspicious, config.FallbackPlagiarized = 0.5, 0.7
+	config.CodeSuspicious, config.CodePlagiarized = 0.3, 0.5
+	t.Cleanup(func() { config = saved })
+}
+
+func closeTo(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestResolveThresholds(t *testing.T) {
+	withThresholdConfig(t)
+
+	tests := []struct {
+		name                    string
+		settings                *WorkSettings
+		source                  string
+		suspicious, plagiarized float64
+		code, fallback          Band
+	}{
+		{"global", nil, "global", 0.7, 0.85, Band{0.3, 0.5}, Band{0.5, 0.7}},
+		{"no overrides", &WorkSettings{}, "global", 0.7, 0.85, Band{0.3, 0.5}, Band{0.5, 0.7}},
+		{"work", &WorkSettings{SuspiciousThreshold: 0.35, SimilarityThreshold: 0.425}, "work", 0.35, 0.425,
+			Band{0.15, 0.25}, Band{0.25, 0.35}},
+		{"only plagiarized", &WorkSettings{SimilarityThreshold: 0.935}, "mixed", 0.7, 0.935,
+			Band{0.3, 0.55}, Band{0.5, 0.77}},
+		{"inverted work", &WorkSettings{SuspiciousThreshold: 0.9, SimilarityThreshold: 0.8}, "global", 0.7, 0.85,
+			Band{0.3, 0.5}, Band{0.5, 0.7}},
+		{"inverted against global", &WorkSettings{SuspiciousThreshold: 0.9}, "global", 0.7, 0.85,
+			Band{0.3, 0.5}, Band{0.5, 0.7}},
+		{"strict work", &WorkSettings{SuspiciousThreshold: 0.8, SimilarityThreshold: 1}, "work", 0.8, 1,
+			Band{0.3 * 0.8 / 0.7, 0.5 / 0.85}, Band{0.5 * 0.8 / 0.7, 0.7 / 0.85}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveThresholds(tt.settings)
+			if got.Source != tt.source || !closeTo(got.Suspicious, tt.suspicious) || !closeTo(got.Plagiarized, tt.plagiarized) {
+				t.Errorf("got %s %v/%v, want %s %v/%v", got.Source, got.Suspicious, got.Plagiarized,
+					tt.source, tt.suspicious, tt.plagiarized)
+			}
+			if !closeTo(got.Code.Suspicious, tt.code.Suspicious) || !closeTo(got.Code.Plagiarized, tt.code.Plagiarized) {
+				t.Errorf("code band %+v, want %+v", *got.Code, tt.code)
+			}
+			if !closeTo(got.Fallback.Suspicious, tt.fallback.Suspicious) || !closeTo(got.Fallback.Plagiarized, tt.fallback.Plagiarized) {
+				t.Errorf("fallback band %+v, want %+v", *got.Fallback, tt.fallback)
+			}
+		})
+	}
+}
+
+// Пороги задания влияют на вердикт по коду и по запасному вектору
+func TestScoreReportUsesWorkBands(t *testing.T) {
+	withThresholdConfig(t)
+	strict := resolveThresholds(&WorkSettings{SuspiciousThreshold: 0.8, SimilarityThreshold: 0.95})
+
+	tests := []struct {
+		name   string
+		report Report
+		global Verdict
+		work   Verdict
+	}{
+		{"code", Report{CodeOverlap: 0.52}, VerdictPlagiarized, VerdictSuspicious},
+		{"fallback", Report{EmbeddingMethod: hashingVectorName, Matches: []Match{{Score: 0.72, Coverage: 1}}},
+			VerdictPlagiarized, VerdictSuspicious},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global, work := tt.report, tt.report
+			scoreReport(&global, resolveThresholds(nil))
+			scoreReport(&work, strict)
+			if global.Verdict != tt.global || work.Verdict != tt.work {
+				t.Errorf("verdicts %s (global) and %s (work), want %s and %s", global.Verdict, work.Verdict, tt.global, tt.work)
+			}
+		})
+	}
+}
+
+func TestCheckThresholdConfig(t *testing.T) {
+	withThresholdConfig(t)
+	if err := checkThresholdConfig(); err != nil {
+		t.Fatalf("default thresholds rejected: %v", err)
+	}
+	config.CodeSuspicious = 0.6
+	if err := checkThresholdConfig(); err == nil {
+		t.Error("inverted code band accepted")
+	}
+}
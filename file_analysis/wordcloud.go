@@ -7,44 +7,49 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 )
 
-func extractKeywords(text string) map[string]int {
-	text = strings.ToLower(text)
-	reg := regexp.MustCompile(`[^a-zа-я0-9\s]`)
-	text = reg.ReplaceAllString(text, " ")
-
-	words := strings.Fields(text)
-
-	stopWords := map[string]bool{
-		"the": true, "a": true, "an": true, "and": true, "or": true, "but": true,
-		"in": true, "on": true, "at": true, "to": true, "for": true, "of": true,
-		"is": true, "are": true, "was": true, "were": true, "be": true, "been": true,
-		"have": true, "has": true, "had": true, "do": true, "does": true, "did": true,
-		"will": true, "would": true, "could": true, "should": true, "may": true, "might": true,
-		"can": true, "this": true, "that": true, "these": true, "those": true,
-		"i": true, "you": true, "he": true, "she": true, "it": true, "we": true, "they": true,
-		"что": true, "это": true, "как": true, "то": true, "все": true, "если": true,
-		"он": true, "она": true, "они": true, "мы": true, "вы": true, "я": true,
-		"и": true, "или": true, "но": true, "не": true, "да": true, "нет": true,
-		"в": true, "на": true, "за": true, "по": true, "от": true, "с": true,
+// extractKeywords считает слова текста без стоп-слов; язык каждого слова берётся из участка,
+// в котором оно стоит, поэтому в смешанном документе работают списки обоих языков
+func extractKeywords(text string, spans []languageSpan) map[string]int {
+	// Словоформы считаются вместе по основе, а в облако попадает самая частая из них:
+	// «алгоритм», «алгоритма» и «алгоритмы» — одно слово
+	stemFreq := make(map[string]int)
+	forms := make(map[string]map[string]int)
+	for _, token := range tokenize(text) {
+		word := token.Text
+		lang := languageAt(spans, token.Start)
+		if len(word) <= 2 {
+			continue
+		}
+		if l := languageFor(word, lang); l != nil && l.StopWords[word] {
+			continue
+		}
+		stem := normalizeWord(word, lang)
+		stemFreq[stem]++
+		if forms[stem] == nil {
+			forms[stem] = make(map[string]int)
+		}
+		forms[stem][word]++
 	}
 
-	wordFreq := make(map[string]int)
-	for _, word := range words {
-		if len(word) > 2 && !stopWords[word] {
-			wordFreq[word]++
+	wordFreq := make(map[string]int, len(stemFreq))
+	for stem, count := range stemFreq {
+		best := ""
+		for form, n := range forms[stem] {
+			if n > forms[stem][best] || n == forms[stem][best] && form < best {
+				best = form
+			}
 		}
+		wordFreq[best] = count
 	}
-
 	return wordFreq
 }
 
-func downloadAndSaveWordCloud(text string) (string, error) {
-	keywords := extractKeywords(text)
+func downloadAndSaveWordCloud(text string, spans []languageSpan) (string, error) {
+	keywords := extractKeywords(text, spans)
 
 	type kv struct {
 		Key   string
@@ -94,13 +99,13 @@ func downloadAndSaveWordCloud(text string) (string, error) {
 		return "", fmt.Errorf("failed to read word cloud data: %w", err)
 	}
 
-	tmpDir := "/tmp"
-	tmpFile := filepath.Join(tmpDir, fmt.Sprintf("wordcloud_%d.png", time.Now().UnixNano()))
+	name := fmt.Sprintf("wordcloud_%d.png", time.Now().UnixNano())
+	path := filepath.Join(config.WordCloudDir, name)
 
-	if err := ioutil.WriteFile(tmpFile, imageData, 0644); err != nil {
+	if err := ioutil.WriteFile(path, imageData, 0644); err != nil {
 		return "", fmt.Errorf("failed to save word cloud image: %w", err)
 	}
 
-	log.Printf("Word cloud saved to: %s", tmpFile)
-	return tmpFile, nil
+	log.Printf("Word cloud saved to: %s", path)
+	return name, nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"net/http"
 )
 
@@ -23,14 +24,27 @@ func handleUpload(uploadDir string) http.HandlerFunc {
 		}
 		defer file.Close()
 
-		if err := saveFile(uploadDir, handler.Filename, file); err != nil {
+		if isArchive(handler.Filename) {
+			if err := checkArchive(handler.Filename, file); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		stored, err := saveFile(uploadDir, handler.Filename, file)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		// stored_name — имя в /files, по нему файл читается при анализе и скачивается
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"success","filename":"` + handler.Filename + `"}`))
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":      "success",
+			"filename":    handler.Filename,
+			"stored_name": stored,
+		})
 	}
 }
 
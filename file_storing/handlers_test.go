@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func upload(t *testing.T, h http.HandlerFunc, fileName, content string) (int, map[string]string) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", fileName)
+	fw.Write([]byte(content))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	var resp map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestUploadStoresUniqueNames(t *testing.T) {
+	dir := t.TempDir()
+	h := handleUpload(dir)
+
+	contents := []string{"первая сдача", "вторая сдача"}
+	var names []string
+	for _, content := range contents {
+		code, resp := upload(t, h, "essay.txt", content)
+		if code != http.StatusOK {
+			t.Fatalf("upload status %d", code)
+		}
+		if resp["filename"] != "essay.txt" || !strings.HasSuffix(resp["stored_name"], "_essay.txt") {
+			t.Fatalf("upload response %v", resp)
+		}
+		names = append(names, resp["stored_name"])
+	}
+	if names[0] == names[1] {
+		t.Fatalf("same-named uploads stored as %q", names[0])
+	}
+	for i, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != contents[i] {
+			t.Errorf("%s contains %q, %v; want %q", name, data, err, contents[i])
+		}
+	}
+}
+
+func TestUploadRejectsBrokenArchive(t *testing.T) {
+	dir := t.TempDir()
+	if code, _ := upload(t, handleUpload(dir), "project.zip", "not a zip"); code != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", code)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("broken archive stored: %v", entries)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -8,25 +11,76 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-func saveFile(uploadDir, filename string, file multipart.File) error {
-	fpath := filepath.Join(uploadDir, filename)
-	out, err := os.Create(fpath)
+// isArchive — загружен ли проект целиком (ZIP, TAR или TAR.GZ); такие файлы распаковывает
+// и проверяет по одному file_analysis
+func isArchive(filename string) bool {
+	name := strings.ToLower(filename)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArchive сверяет сигнатуру архива с расширением, чтобы не хранить заведомо битые архивы
+func checkArchive(filename string, file multipart.File) error {
+	header := make([]byte, 262)
+	n, _ := file.ReadAt(header, 0)
+	header = header[:n]
+
+	name := strings.ToLower(filename)
+	var ok bool
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		ok = bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06"))
+	case strings.HasSuffix(name, ".tar"):
+		ok = n >= 262 && bytes.HasPrefix(header[257:], []byte("ustar"))
+	default:
+		ok = bytes.HasPrefix(header, []byte{0x1f, 0x8b})
+	}
+	if !ok {
+		return fmt.Errorf("file is not a valid archive")
+	}
+	return nil
+}
+
+// storedName — уникальное имя загрузки в хранилище. Имя от клиента только дописывается
+// в конец: одинаково названные сдачи не перезаписывают друг друга, пока ждут анализа.
+func storedName(filename string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + "_" + filepath.Base(filename), nil
+}
+
+// saveFile сохраняет загрузку под новым уникальным именем и возвращает его
+func saveFile(uploadDir, filename string, file multipart.File) (string, error) {
+	name, err := storedName(filename)
+	if err != nil {
+		log.Printf("Failed to generate name for %s: %v", filename, err)
+		return "", fmt.Errorf("failed to save file")
+	}
+	fpath := filepath.Join(uploadDir, name)
+	out, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
 	if err != nil {
 		log.Printf("Failed to create file %s: %v", fpath, err)
-		return fmt.Errorf("failed to save file")
+		return "", fmt.Errorf("failed to save file")
 	}
 	defer out.Close()
 
 	if _, err := io.Copy(out, file); err != nil {
 		log.Printf("Failed to save file content %s: %v", fpath, err)
 		os.Remove(fpath)
-		return fmt.Errorf("failed to save file content")
+		return "", fmt.Errorf("failed to save file content")
 	}
 
-	log.Printf("Successfully uploaded file: %s", filename)
-	return nil
+	log.Printf("Successfully uploaded file: %s as %s", filename, name)
+	return name, nil
 }
 
 func serveFile(w http.ResponseWriter, r *http.Request, uploadDir, filename string) error {
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type Principal struct {
+	Subject string `json:"subject"`
+	Name    string `json:"name"`
+	Role    Role   `json:"role"`
+	Method  string `json:"method"`
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func principalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Authenticator проверяет статические API-ключи (для скриптов)
+// и JWT bearer-токены OIDC-провайдера.
+type Authenticator struct {
+	apiKeys map[string]Principal
+	jwt     *jwtVerifier
+}
+
+func newAuthenticator(apiKeys string, jwt *jwtVerifier) *Authenticator {
+	a := &Authenticator{
+		apiKeys: parseAPIKeys(apiKeys),
+		jwt:     jwt,
+	}
+	if len(a.apiKeys) == 0 && a.jwt == nil {
+		log.Println("WARNING: no API keys or OIDC issuer configured, all API requests will be rejected")
+	}
+	return a
+}
+
+// parseAPIKeys разбирает строку вида "key1=Иванов Иван,key2=Петров Пётр:teacher".
+// Роль по умолчанию — student. Имя только отображается: субъект ключа —
+// "apikey:" и начало хеша ключа, поэтому ключи с одинаковыми именами
+// не получают доступ к сдачам и заданиям друг друга.
+func parseAPIKeys(raw string) map[string]Principal {
+	keys := make(map[string]Principal)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, name, ok := strings.Cut(entry, "=")
+		key, name = strings.TrimSpace(key), strings.TrimSpace(name)
+		if !ok || key == "" || name == "" {
+			log.Printf("Ignoring malformed API key entry")
+			continue
+		}
+		role := RoleStudent
+		if n, r, ok := strings.Cut(name, ":"); ok {
+			name, role = strings.TrimSpace(n), parseRole(r)
+		}
+		if role == "" {
+			log.Printf("Ignoring API key entry for %s with unknown role", name)
+			continue
+		}
+		hashed := hashAPIKey(key)
+		p := Principal{Subject: "apikey:" + hashed[:apiKeySubjectLen], Name: name, Role: role, Method: "api_key"}
+		if _, dup := keys[hashed]; dup {
+			log.Printf("Ignoring duplicate API key entry for %s", name)
+			continue
+		}
+		log.Printf("API key for %s (%s) authenticates as %s", name, role, p.Subject)
+		keys[hashed] = p
+	}
+	return keys
+}
+
+// apiKeySubjectLen — длина префикса хеша в субъекте API-ключа
+const apiKeySubjectLen = 16
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.checkAPIKey(key)
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return Principal{}, errMissingCredentials
+	}
+	if a.jwt == nil {
+		return Principal{}, errInvalidToken
+	}
+	return a.jwt.Verify(r.Context(), strings.TrimSpace(token))
+}
+
+func (a *Authenticator) checkAPIKey(key string) (Principal, error) {
+	hashed := hashAPIKey(key)
+	for stored, p := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hashed)) == 1 {
+			return p, nil
+		}
+	}
+	return Principal{}, errInvalidAPIKey
+}
+
+// authMiddleware пропускает без проверки только /health
+func authMiddleware(auth *Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p, err := auth.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="antiplag"`)
+			writeJSONError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys := parseAPIKeys("k1=Иванов Иван, k2=Петров Пётр:teacher,k3=Admin:ADMIN,bad,=Нет ключа,k4=,k5=Сидоров:guest,k6=Иванов Иван")
+	a := &Authenticator{apiKeys: keys}
+
+	tests := []struct {
+		key  string
+		name string
+		role Role
+		ok   bool
+	}{
+		{"k1", "Иванов Иван", RoleStudent, true},
+		{"k2", "Петров Пётр", RoleTeacher, true},
+		{"k3", "Admin", RoleAdmin, true},
+		{"k4", "", "", false},
+		{"k5", "", "", false},
+		{"k6", "Иванов Иван", RoleStudent, true},
+		{"unknown", "", "", false},
+	}
+	subjects := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			p, err := a.checkAPIKey(tt.key)
+			if !tt.ok {
+				if err != errInvalidAPIKey {
+					t.Fatalf("checkAPIKey(%q) = %+v, %v; want errInvalidAPIKey", tt.key, p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkAPIKey(%q): %v", tt.key, err)
+			}
+			if p.Name != tt.name || p.Role != tt.role || p.Method != "api_key" {
+				t.Errorf("checkAPIKey(%q) = %+v", tt.key, p)
+			}
+			if !strings.HasPrefix(p.Subject, "apikey:") || p.Subject == "apikey:"+tt.name {
+				t.Errorf("subject %q is not a namespaced key id", p.Subject)
+			}
+			subjects[tt.key] = p.Subject
+		})
+	}
+	if len(keys) != 4 {
+		t.Errorf("parsed %d keys, want 4", len(keys))
+	}
+	// Одинаковое имя не делает ключи одним субъектом
+	if subjects["k1"] == subjects["k6"] {
+		t.Errorf("keys with the same name share subject %q", subjects["k1"])
+	}
+	// Субъект не зависит от имени и не меняется между запусками
+	if again := parseAPIKeys("k1=Другое имя")[hashAPIKey("k1")]; again.Subject != subjects["k1"] {
+		t.Errorf("subject changed from %q to %q after renaming", subjects["k1"], again.Subject)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	jwt := newJWTVerifier("", "", "", testHMACSecret, "", "")
+	auth := newAuthenticator("student-key=Иванов Иван", jwt)
+	handler := authMiddleware(auth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := principalFrom(r.Context())
+		w.Write([]byte(p.Subject))
+	}))
+	token := signToken(t, "HS256", "", []byte(testHMACSecret), map[string]interface{}{
+		"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+		subject string
+	}{
+		{"health without credentials", "/health", nil, http.StatusOK, ""},
+		{"no credentials", "/api/works", nil, http.StatusUnauthorized, ""},
+		{"api key", "/api/works", map[string]string{"X-API-Key": "student-key"}, http.StatusOK, "apikey:" + hashAPIKey("student-key")[:apiKeySubjectLen]},
+		{"wrong api key", "/api/works", map[string]string{"X-API-Key": "other"}, http.StatusUnauthorized, ""},
+		// Неверный ключ не подменяется токеном
+		{"wrong api key with token", "/api/works", map[string]string{"X-API-Key": "other", "Authorization": "Bearer " + token}, http.StatusUnauthorized, ""},
+		{"bearer", "/api/works", map[string]string{"Authorization": "Bearer " + token}, http.StatusOK, "user-1"},
+		{"lowercase scheme", "/api/works", map[string]string{"Authorization": "bearer " + token}, http.StatusOK, "user-1"},
+		{"basic scheme", "/api/works", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, http.StatusUnauthorized, ""},
+		{"bad token", "/api/works", map[string]string{"Authorization": "Bearer " + token + "x"}, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.subject {
+				t.Errorf("subject %q, want %q", rec.Body.String(), tt.subject)
+			}
+		})
+	}
+}
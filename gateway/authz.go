@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type Role string
+
+const (
+	RoleStudent Role = "student"
+	RoleTeacher Role = "teacher"
+	RoleAdmin   Role = "admin"
+)
+
+func parseRole(s string) Role {
+	switch Role(strings.ToLower(strings.TrimSpace(s))) {
+	case RoleStudent:
+		return RoleStudent
+	case RoleTeacher:
+		return RoleTeacher
+	case RoleAdmin:
+		return RoleAdmin
+	}
+	return ""
+}
+
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 3
+	case RoleTeacher:
+		return 2
+	case RoleStudent:
+		return 1
+	}
+	return 0
+}
+
+// canManageWork — админ или преподаватель-владелец задания
+func canManageWork(p Principal, works *WorkStore, workID string) bool {
+	if p.Role == RoleAdmin {
+		return true
+	}
+	if p.Role != RoleTeacher {
+		return false
+	}
+	work, ok := works.Get(workID)
+	return ok && work.Owner == p.Subject
+}
+
+func forbidden(w http.ResponseWriter, message string) {
+	writeJSONError(w, http.StatusForbidden, message)
+}
+
+// redactForeign удаляет имена файлов и отправителей чужих работ
+// (например, в списке совпадений), чтобы студент не видел чужие сдачи. self — subject студента.
+func redactForeign(v interface{}, self string) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		if sender, ok := node["sender"].(string); ok && sender != self {
+			delete(node, "sender")
+			delete(node, "sender_name")
+			delete(node, "file_name")
+		}
+		for _, child := range node {
+			redactForeign(child, self)
+		}
+	case []interface{}:
+		for _, child := range node {
+			redactForeign(child, self)
+		}
+	}
+}
+
+func redactReport(report json.RawMessage, self string) json.RawMessage {
+	if len(report) == 0 {
+		return report
+	}
+	var v interface{}
+	if err := json.Unmarshal(report, &v); err != nil {
+		return nil
+	}
+	redactForeign(v, self)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
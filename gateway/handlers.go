@@ -6,15 +6,27 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
-// CORS middleware для поддержки запросов с frontend
-func corsMiddleware(next http.Handler) http.Handler {
+// CORS middleware для поддержки запросов с frontend.
+// Разрешены только источники из allowedOrigins ("*" — любой).
+func corsMiddleware(allowedOrigins []string, next http.Handler) http.Handler {
+	allowed := make(map[string]bool)
+	for _, origin := range allowedOrigins {
+		allowed[strings.TrimSuffix(strings.TrimSpace(origin), "/")] = true
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		origin := r.Header.Get("Origin")
+		if origin != "" && (allowed["*"] || allowed[origin]) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		}
+		w.Header().Add("Vary", "Origin")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -25,7 +37,13 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func handleSubmit(fileStoringURL, fileAnalysisURL string) http.HandlerFunc {
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func handleSubmit(fileStoringURL string, jobs *JobQueue, works *WorkStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -40,6 +58,18 @@ func handleSubmit(fileStoringURL, fileAnalysisURL string) http.HandlerFunc {
 		}
 		defer file.Close()
 
+		work, ok := works.Get(r.FormValue("work_id"))
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "unknown work")
+			return
+		}
+
+		late := work.IsLate(time.Now())
+		if late && work.LatePolicy == LateReject {
+			forbidden(w, "the deadline for this work has passed")
+			return
+		}
+
 		// Upload file to file_storing service
 		buf := &bytes.Buffer{}
 		mw := multipart.NewWriter(buf)
@@ -54,34 +84,144 @@ func handleSubmit(fileStoringURL, fileAnalysisURL string) http.HandlerFunc {
 		req, _ := http.NewRequest("POST", fileStoringURL+"/upload", buf)
 		req.Header.Set("Content-Type", mw.FormDataContentType())
 		resp, err := http.DefaultClient.Do(req)
-		if err != nil || resp.StatusCode != http.StatusOK {
+		if err != nil {
+			http.Error(w, "Failed to upload file to storage service", http.StatusBadGateway)
+			return
+		}
+		if resp.StatusCode == http.StatusBadRequest {
+			// Хранилище отклонило сам файл (например, битый архив)
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+			resp.Body.Close()
+			http.Error(w, strings.TrimSpace(string(msg)), http.StatusBadRequest)
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
 			http.Error(w, "Failed to upload file to storage service", http.StatusBadGateway)
 			return
 		}
 
-		var result map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&result)
+		// Хранилище сохраняет файл под своим уникальным именем, анализ читает именно его
+		var stored struct {
+			StoredName string `json:"stored_name"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&stored)
 		resp.Body.Close()
-
-		// Call file_analysis service
-		analysisData := map[string]string{
-			"file_name": handler.Filename,
-			"sender":    r.FormValue("sender"),
-			"work_id":   r.FormValue("work_id"),
+		if err != nil || stored.StoredName == "" {
+			http.Error(w, "Storage service did not return a file name", http.StatusBadGateway)
+			return
 		}
-		body, _ := json.Marshal(analysisData)
-		resp2, err := http.Post(fileAnalysisURL+"/analyze", "application/json", bytes.NewReader(body))
+
+		// Отправитель определяется по аутентификации, поле формы sender игнорируется
+		principal, _ := principalFrom(r.Context())
+
+		// Анализ выполняется асинхронно, клиент получает ID задания
+		job, err := jobs.Submit(analysisRequest{
+			FileName:   handler.Filename,
+			StoredName: stored.StoredName,
+			Sender:     principal.Subject,
+			SenderName: principal.Name,
+			WorkID:     work.ID,
+			Late:       late,
+			Settings: &WorkSettings{
+				SuspiciousThreshold: work.SuspiciousThreshold,
+				SimilarityThreshold: work.SimilarityThreshold,
+				Analyzers:           work.Analyzers,
+				Mode:                work.Mode,
+			},
+		}, principal.Subject)
 		if err != nil {
-			http.Error(w, "Failed to call analysis service", http.StatusBadGateway)
+			http.Error(w, "Failed to queue analysis: "+err.Error(), http.StatusServiceUnavailable)
 			return
 		}
 
-		io.Copy(w, resp2.Body)
-		resp2.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"job_id":     job.ID,
+			"status":     job.Status,
+			"status_url": "/api/jobs/" + job.ID,
+			"late":       late,
+		})
+	}
+}
+
+// handleJobs обслуживает /api/jobs/{id}, /api/jobs/{id}/report и /api/jobs/{id}/wordcloud
+func handleJobs(fileAnalysisURL string, jobs *JobQueue, works *WorkStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/"), "/")
+		if parts[0] == "" || len(parts) > 2 {
+			http.Error(w, "Invalid request path", http.StatusBadRequest)
+			return
+		}
+
+		job, ok := jobs.Get(parts[0])
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+
+		principal, _ := principalFrom(r.Context())
+		if job.owner != principal.Subject && !canManageWork(principal, works, job.WorkID) {
+			forbidden(w, "access to this job is denied")
+			return
+		}
+		if principal.Role == RoleStudent {
+			job.Report = redactReport(job.Report, principal.Subject)
+		}
+
+		if len(parts) == 1 {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(job)
+			return
+		}
+
+		if job.Status != JobDone {
+			http.Error(w, "Job is "+string(job.Status), http.StatusConflict)
+			return
+		}
+
+		switch parts[1] {
+		case "report":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(job.Report)
+		case "wordcloud":
+			var report struct {
+				WordCloud string `json:"word_cloud"`
+			}
+			json.Unmarshal(job.Report, &report)
+			if report.WordCloud == "" {
+				http.Error(w, "Word cloud not available", http.StatusNotFound)
+				return
+			}
+
+			resp, err := http.Get(fileAnalysisURL + "/wordclouds/" + report.WordCloud)
+			if err != nil {
+				http.Error(w, "Failed to reach analysis service", http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				http.Error(w, "Word cloud not found", http.StatusNotFound)
+				return
+			}
+
+			w.Header().Set("Content-Type", "image/png")
+			io.Copy(w, resp.Body)
+		default:
+			http.Error(w, "Invalid request path", http.StatusBadRequest)
+		}
 	}
 }
 
-func handleReports(fileAnalysisURL string) http.HandlerFunc {
+func handleReports(fileAnalysisURL string, works *WorkStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -95,23 +235,133 @@ func handleReports(fileAnalysisURL string) http.HandlerFunc {
 		}
 		workid := parts[len(parts)-2]
 
+		// Студент видит только свои отчёты; преподаватель — все отчёты своих заданий
+		principal, _ := principalFrom(r.Context())
+		manager := canManageWork(principal, works, workid)
+		if !manager && principal.Role != RoleStudent {
+			forbidden(w, "you do not own this work")
+			return
+		}
+
 		url := fileAnalysisURL + "/reports/" + workid
 		resp, err := http.Get(url)
 		if err != nil {
 			http.Error(w, "Failed to reach analysis service", http.StatusBadGateway)
 			return
 		}
+		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
 			http.Error(w, "Report not found", http.StatusNotFound)
 			return
 		}
 
+		if manager {
+			w.Header().Set("Content-Type", "application/json")
+			io.Copy(w, resp.Body)
+			return
+		}
+
+		var reports []map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+			http.Error(w, "Invalid response from analysis service", http.StatusBadGateway)
+			return
+		}
+
+		own := []map[string]interface{}{}
+		for _, report := range reports {
+			if sender, _ := report["sender"].(string); sender == principal.Subject {
+				redactForeign(report, principal.Subject)
+				own = append(own, report)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(own)
+	}
+}
+
+// handleCompare проксирует выравнивание двух сдач из file_analysis.
+// Доступно преподавателю, владеющему заданиями обеих сдач, и админу.
+func handleCompare(fileAnalysisURL string, works *WorkStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		principal, _ := principalFrom(r.Context())
+		if principal.Role != RoleTeacher && principal.Role != RoleAdmin {
+			forbidden(w, "only teachers and admins can compare submissions")
+			return
+		}
+
+		// Права проверяются по заданиям сдач до выравнивания: оно дорогое, а чужие
+		// сдачи не должны его запускать
+		for _, key := range []string{"a", "b"} {
+			workID, status := submissionWorkID(fileAnalysisURL, r.URL.Query().Get(key))
+			if status != http.StatusOK {
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			if !canManageWork(principal, works, workID) {
+				forbidden(w, "you do not own the works of both submissions")
+				return
+			}
+		}
+
+		query := url.Values{}
+		for _, key := range []string{"a", "b", "with_text"} {
+			if v := r.URL.Query().Get(key); v != "" {
+				query.Set(key, v)
+			}
+		}
+
+		resp, err := http.Get(fileAnalysisURL + "/align?" + query.Encode())
+		if err != nil {
+			http.Error(w, "Failed to reach analysis service", http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			http.Error(w, strings.TrimSpace(string(body)), resp.StatusCode)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		io.Copy(w, resp.Body)
-		resp.Body.Close()
 	}
 }
 
+// submissionWorkID узнаёт задание сдачи у file_analysis; вместо ошибки возвращает HTTP-статус
+func submissionWorkID(fileAnalysisURL, id string) (string, int) {
+	if id == "" {
+		return "", http.StatusBadRequest
+	}
+	resp, err := http.Get(fileAnalysisURL + "/submissions/" + url.PathEscape(id))
+	if err != nil {
+		return "", http.StatusBadGateway
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", http.StatusNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", http.StatusBadGateway
+	}
+
+	var sub struct {
+		WorkID string `json:"work_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		return "", http.StatusBadGateway
+	}
+	return sub.WorkID, http.StatusOK
+}
+
 func handleGatewayHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
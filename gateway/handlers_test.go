@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAPIKeys = "alice-key=Alice,bob-key=Bob,teacher-key=Teacher:teacher,other-key=Other:teacher,admin-key=Admin:admin"
+
+func keySubject(key string) string {
+	return "apikey:" + hashAPIKey(key)[:apiKeySubjectLen]
+}
+
+// fakeBackends — file_storing и file_analysis на httptest: хранилище выдаёт каждой загрузке
+// своё имя, анализ читает файл по stored_name и возвращает его содержимое в отчёте
+type fakeBackends struct {
+	mu       sync.Mutex
+	files    map[string]string
+	uploads  int
+	analyzed []analysisRequest
+}
+
+func (b *fakeBackends) storing(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		b.mu.Lock()
+		b.uploads++
+		name := fmt.Sprintf("%032d_%s", b.uploads, header.Filename)
+		b.files[name] = string(data)
+		b.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"status": "success", "filename": header.Filename, "stored_name": name})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (b *fakeBackends) analysis(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req analysisRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		b.mu.Lock()
+		b.analyzed = append(b.analyzed, req)
+		text, ok := b.files[req.StoredName]
+		b.mu.Unlock()
+		if !ok {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"file_name": req.FileName, "sender": req.Sender, "work_id": req.WorkID, "text": text,
+			"matches": []map[string]interface{}{{"sender": "someone-else", "file_name": "their.txt", "similarity": 0.9}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestGateway(t *testing.T) (http.Handler, *fakeBackends) {
+	t.Helper()
+	backends := &fakeBackends{files: make(map[string]string)}
+	storing, analysis := backends.storing(t), backends.analysis(t)
+
+	works, err := newWorkStore(filepath.Join(t.TempDir(), "works.json"), 0.7, 0.85)
+	if err != nil {
+		t.Fatalf("newWorkStore: %v", err)
+	}
+	if _, err := works.Create(Work{ID: "w1", Title: "Эссе", Owner: keySubject("teacher-key")}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	jobs := newJobQueue(analysis.URL, 2, 16, time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/submit", handleSubmit(storing.URL, jobs, works))
+	mux.HandleFunc("/api/jobs/", handleJobs(analysis.URL, jobs, works))
+	return authMiddleware(newAuthenticator(testAPIKeys, nil), mux), backends
+}
+
+func doRequest(h http.Handler, req *http.Request, key string) *httptest.ResponseRecorder {
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func submit(t *testing.T, h http.Handler, key, workID, fileName, content string) string {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("work_id", workID)
+	mw.WriteField("sender", "spoofed")
+	fw, _ := mw.CreateFormFile("file", fileName)
+	fw.Write([]byte(content))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/submit", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := doRequest(h, req, key)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("submit status %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		JobID     string `json:"job_id"`
+		StatusURL string `json:"status_url"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.JobID == "" || rec.Header().Get("Location") != resp.StatusURL {
+		t.Fatalf("submit response %s, Location %q", rec.Body.String(), rec.Header().Get("Location"))
+	}
+	return resp.JobID
+}
+
+// waitJob опрашивает /api/jobs/{id}, пока задание не завершится
+func waitJob(t *testing.T, h http.Handler, key, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := doRequest(h, httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil), key)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("job status %d: %s", rec.Code, rec.Body.String())
+		}
+		var job Job
+		json.Unmarshal(rec.Body.Bytes(), &job)
+		if job.Status == JobDone || job.Status == JobFailed {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still %s", id, job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSubmitJobResult(t *testing.T) {
+	h, backends := newTestGateway(t)
+
+	// Одинаковые имена файлов у разных студентов не смешиваются
+	aliceJob := submit(t, h, "alice-key", "w1", "essay.txt", "текст Алисы")
+	bobJob := submit(t, h, "bob-key", "w1", "essay.txt", "текст Боба")
+
+	for _, tt := range []struct{ key, job, text string }{
+		{"alice-key", aliceJob, "текст Алисы"},
+		{"bob-key", bobJob, "текст Боба"},
+	} {
+		job := waitJob(t, h, tt.key, tt.job)
+		if job.Status != JobDone {
+			t.Fatalf("job %s failed: %s", tt.job, job.Error)
+		}
+		if job.FileName != "essay.txt" || job.Sender != keySubject(tt.key) || job.WorkID != "w1" {
+			t.Errorf("job %+v", job)
+		}
+
+		rec := doRequest(h, httptest.NewRequest(http.MethodGet, "/api/jobs/"+tt.job+"/report", nil), tt.key)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("report status %d: %s", rec.Code, rec.Body.String())
+		}
+		var report struct {
+			FileName string                   `json:"file_name"`
+			Sender   string                   `json:"sender"`
+			Text     string                   `json:"text"`
+			Matches  []map[string]interface{} `json:"matches"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &report)
+		if report.Text != tt.text || report.FileName != "essay.txt" || report.Sender != keySubject(tt.key) {
+			t.Errorf("report %s, want text %q of %s", rec.Body.String(), tt.text, keySubject(tt.key))
+		}
+		// Студент не видит имя файла и автора чужой сдачи
+		if len(report.Matches) != 1 || report.Matches[0]["file_name"] != nil || report.Matches[0]["sender"] != nil {
+			t.Errorf("foreign match not redacted: %v", report.Matches)
+		}
+	}
+
+	backends.mu.Lock()
+	defer backends.mu.Unlock()
+	if len(backends.analyzed) != 2 || backends.analyzed[0].StoredName == backends.analyzed[1].StoredName {
+		t.Errorf("analysis requests %+v, want distinct stored names", backends.analyzed)
+	}
+}
+
+func TestSubmitRejectsUnknownWork(t *testing.T) {
+	h, _ := newTestGateway(t)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("work_id", "missing")
+	fw, _ := mw.CreateFormFile("file", "essay.txt")
+	fw.Write([]byte("text"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/submit", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if rec := doRequest(h, req, "alice-key"); rec.Code != http.StatusNotFound {
+		t.Errorf("status %d, want 404: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJobAccess(t *testing.T) {
+	h, _ := newTestGateway(t)
+	id := submit(t, h, "alice-key", "w1", "essay.txt", "текст Алисы")
+	waitJob(t, h, "alice-key", id)
+
+	tests := []struct {
+		name   string
+		key    string
+		path   string
+		status int
+	}{
+		{"owner", "alice-key", "/api/jobs/" + id, http.StatusOK},
+		{"owner report", "alice-key", "/api/jobs/" + id + "/report", http.StatusOK},
+		{"other student", "bob-key", "/api/jobs/" + id, http.StatusForbidden},
+		{"other student report", "bob-key", "/api/jobs/" + id + "/report", http.StatusForbidden},
+		{"work owner", "teacher-key", "/api/jobs/" + id + "/report", http.StatusOK},
+		{"other teacher", "other-key", "/api/jobs/" + id, http.StatusForbidden},
+		{"admin", "admin-key", "/api/jobs/" + id + "/report", http.StatusOK},
+		{"no credentials", "", "/api/jobs/" + id, http.StatusUnauthorized},
+		{"unknown job", "alice-key", "/api/jobs/0123", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(h, httptest.NewRequest(http.MethodGet, tt.path, nil), tt.key)
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+		})
+	}
+
+	// Преподаватель-владелец задания видит совпадения без сокрытия
+	rec := doRequest(h, httptest.NewRequest(http.MethodGet, "/api/jobs/"+id+"/report", nil), "teacher-key")
+	var report struct {
+		Matches []map[string]interface{} `json:"matches"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	if len(report.Matches) != 1 || report.Matches[0]["file_name"] != "their.txt" {
+		t.Errorf("teacher sees matches %v", report.Matches)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+var errQueueFull = errors.New("job queue is full")
+
+// WorkSettings — настройки проверки задания, передаваемые в file_analysis
+type WorkSettings struct {
+	SuspiciousThreshold float64  `json:"suspicious_threshold,omitempty"`
+	SimilarityThreshold float64  `json:"similarity_threshold,omitempty"`
+	Analyzers           []string `json:"analyzers,omitempty"`
+	Mode                string   `json:"mode,omitempty"`
+}
+
+// analysisRequest — тело запроса к file_analysis /analyze
+type analysisRequest struct {
+	FileName   string        `json:"file_name"`
+	StoredName string        `json:"stored_name"`           // имя файла в хранилище
+	Sender     string        `json:"sender"`                // subject отправителя
+	SenderName string        `json:"sender_name,omitempty"` // отображаемое имя, только для интерфейса
+	WorkID     string        `json:"work_id"`
+	Late       bool          `json:"late,omitempty"`
+	Settings   *WorkSettings `json:"settings,omitempty"`
+}
+
+type Job struct {
+	ID         string          `json:"id"`
+	Status     JobStatus       `json:"status"`
+	FileName   string          `json:"file_name"`
+	Sender     string          `json:"sender"`
+	SenderName string          `json:"sender_name,omitempty"`
+	WorkID     string          `json:"work_id"`
+	Late       bool            `json:"late,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Report     json.RawMessage `json:"report,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+
+	owner   string
+	request analysisRequest
+}
+
+// JobQueue хранит задания в памяти и обрабатывает их пулом воркеров,
+// чтобы /api/submit не ждал окончания анализа.
+type JobQueue struct {
+	mu          sync.RWMutex
+	jobs        map[string]*Job
+	queue       chan string
+	analysisURL string
+	client      *http.Client
+	ttl         time.Duration
+}
+
+func newJobQueue(analysisURL string, workers, size int, ttl time.Duration) *JobQueue {
+	q := &JobQueue{
+		jobs:        make(map[string]*Job),
+		queue:       make(chan string, size),
+		analysisURL: analysisURL,
+		client:      &http.Client{Timeout: 10 * time.Minute},
+		ttl:         ttl,
+	}
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	go q.janitor()
+	return q
+}
+
+// Submit ставит анализ в очередь; owner — subject отправителя
+func (q *JobQueue) Submit(req analysisRequest, owner string) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:         id,
+		Status:     JobQueued,
+		FileName:   req.FileName,
+		Sender:     req.Sender,
+		SenderName: req.SenderName,
+		WorkID:     req.WorkID,
+		Late:       req.Late,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		owner:      owner,
+		request:    req,
+	}
+
+	// Копия снимается до постановки в очередь: после неё задание уже меняет воркер
+	q.mu.Lock()
+	q.jobs[id] = job
+	queued := *job
+	q.mu.Unlock()
+
+	select {
+	case q.queue <- id:
+	default:
+		q.mu.Lock()
+		delete(q.jobs, id)
+		q.mu.Unlock()
+		return Job{}, errQueueFull
+	}
+
+	log.Printf("Job %s queued: %s (%s, %s)", id, req.FileName, req.Sender, req.WorkID)
+	return queued, nil
+}
+
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (q *JobQueue) update(id string, fn func(*Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job, ok := q.jobs[id]; ok {
+		fn(job)
+		job.UpdatedAt = time.Now()
+	}
+}
+
+func (q *JobQueue) worker() {
+	for id := range q.queue {
+		q.run(id)
+	}
+}
+
+func (q *JobQueue) run(id string) {
+	var req analysisRequest
+	q.update(id, func(job *Job) {
+		job.Status = JobRunning
+		req = job.request
+	})
+
+	report, err := q.analyze(req)
+	if err != nil {
+		log.Printf("Job %s failed: %v", id, err)
+		q.update(id, func(job *Job) {
+			job.Status = JobFailed
+			job.Error = err.Error()
+		})
+		return
+	}
+
+	q.update(id, func(job *Job) {
+		job.Status = JobDone
+		job.Report = report
+	})
+	log.Printf("Job %s done", id)
+}
+
+func (q *JobQueue) analyze(req analysisRequest) (json.RawMessage, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal analysis request: %w", err)
+	}
+
+	resp, err := q.client.Post(q.analysisURL+"/analyze", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to call analysis service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analysis response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("analysis service error [%d]: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("analysis service returned invalid JSON")
+	}
+
+	return json.RawMessage(data), nil
+}
+
+// janitor удаляет завершённые задания старше ttl
+func (q *JobQueue) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-q.ttl)
+		q.mu.Lock()
+		for id, job := range q.jobs {
+			finished := job.Status == JobDone || job.Status == JobFailed
+			if finished && job.UpdatedAt.Before(cutoff) {
+				delete(q.jobs, id)
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errInvalidAPIKey      = errors.New("invalid API key")
+	errInvalidToken       = errors.New("invalid token")
+	errTokenExpired       = errors.New("token expired")
+)
+
+const (
+	jwtLeeway       = time.Minute
+	jwksRefreshWait = time.Minute
+	jwksMaxAge      = time.Hour
+)
+
+// jwtVerifier проверяет подпись и стандартные claims токенов.
+// RS256/ES256 проверяются по JWKS издателя, HS256 — по общему секрету
+// (локальная замена OIDC-провайдера для разработки и тестов).
+type jwtVerifier struct {
+	issuer     string
+	audience   string
+	jwksURL    string
+	hmacKey    []byte
+	nameClaim  string
+	rolesClaim string
+	client     *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newJWTVerifier(issuer, audience, jwksURL, hmacSecret, nameClaim, rolesClaim string) *jwtVerifier {
+	if issuer == "" && hmacSecret == "" {
+		return nil
+	}
+	if nameClaim == "" {
+		nameClaim = "preferred_username"
+	}
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	return &jwtVerifier{
+		issuer:     strings.TrimSuffix(issuer, "/"),
+		audience:   audience,
+		jwksURL:    jwksURL,
+		hmacKey:    []byte(hmacSecret),
+		nameClaim:  nameClaim,
+		rolesClaim: rolesClaim,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (v *jwtVerifier) Verify(ctx context.Context, token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, errInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Principal{}, errInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, errInvalidToken
+	}
+
+	if err := v.checkSignature(ctx, header, parts[0]+"."+parts[1], signature); err != nil {
+		return Principal{}, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, errInvalidToken
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return Principal{}, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return Principal{}, errInvalidToken
+	}
+
+	name := subject
+	for _, claim := range []string{v.nameClaim, "name", "email"} {
+		if s, ok := claims[claim].(string); ok && s != "" {
+			name = s
+			break
+		}
+	}
+
+	return Principal{Subject: subject, Name: name, Role: rolesFromClaim(claims[v.rolesClaim]), Method: "bearer"}, nil
+}
+
+// rolesFromClaim выбирает наивысшую известную роль из claim (строка или массив)
+func rolesFromClaim(claim interface{}) Role {
+	var values []string
+	switch c := claim.(type) {
+	case string:
+		values = strings.Fields(c)
+	case []interface{}:
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	best := RoleStudent
+	for _, value := range values {
+		if role := parseRole(value); role != "" && role.rank() > best.rank() {
+			best = role
+		}
+	}
+	return best
+}
+
+func (v *jwtVerifier) checkSignature(ctx context.Context, header jwtHeader, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch header.Alg {
+	case "HS256":
+		if len(v.hmacKey) == 0 {
+			return errInvalidToken
+		}
+		mac := hmac.New(sha256.New, v.hmacKey)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errInvalidToken
+		}
+		return nil
+	case "RS256", "ES256":
+		key, err := v.publicKey(ctx, header.Kid)
+		if err != nil {
+			return err
+		}
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			if header.Alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if header.Alg == "ES256" && len(signature) == 64 {
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				if ecdsa.Verify(k, digest[:], r, s) {
+					return nil
+				}
+			}
+		}
+		return errInvalidToken
+	default:
+		return errInvalidToken
+	}
+}
+
+func (v *jwtVerifier) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errInvalidToken
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return errTokenExpired
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return errInvalidToken
+	}
+
+	if v.issuer != "" {
+		iss, _ := claims["iss"].(string)
+		if strings.TrimSuffix(iss, "/") != v.issuer {
+			return errInvalidToken
+		}
+	}
+
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return errInvalidToken
+	}
+
+	return nil
+}
+
+func hasAudience(aud interface{}, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []interface{}:
+		for _, item := range a {
+			if s, ok := item.(string); ok && s == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *jwtVerifier) publicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetchedAt) > jwksMaxAge
+	if ok && !stale {
+		return key, nil
+	}
+
+	// Неизвестный kid — возможно, издатель сменил ключи; обновляем не чаще раза в минуту
+	if stale || time.Since(v.fetchedAt) > jwksRefreshWait {
+		keys, err := v.fetchJWKS(ctx)
+		if err != nil {
+			if ok {
+				return key, nil
+			}
+			return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+		}
+		v.keys = keys
+		v.fetchedAt = time.Now()
+	}
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errInvalidToken
+}
+
+func (v *jwtVerifier) fetchJWKS(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := v.jwksURL
+	if jwksURL == "" {
+		if v.issuer == "" {
+			return nil, errors.New("no issuer configured")
+		}
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, err
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (v *jwtVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testHMACSecret = "test-secret"
+
+// testIssuer — OIDC-издатель на httptest: discovery и JWKS с подменяемым набором ключей
+type testIssuer struct {
+	server  *httptest.Server
+	fetches atomic.Int32
+
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	iss := &testIssuer{keys: make(map[string]crypto.PublicKey)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.server.URL, "jwks_uri": iss.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		iss.mu.Lock()
+		defer iss.mu.Unlock()
+		var keys []map[string]string
+		for kid, key := range iss.keys {
+			switch k := key.(type) {
+			case *rsa.PublicKey:
+				keys = append(keys, map[string]string{
+					"kty": "RSA", "kid": kid, "use": "sig",
+					"n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes()),
+				})
+			case *ecdsa.PublicKey:
+				keys = append(keys, map[string]string{
+					"kty": "EC", "kid": kid, "crv": "P-256",
+					"x": b64(k.X.FillBytes(make([]byte, 32))), "y": b64(k.Y.FillBytes(make([]byte, 32))),
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	iss.server = httptest.NewServer(mux)
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *testIssuer) publish(kid string, key crypto.PublicKey) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.keys[kid] = key
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signToken собирает JWT; key — []byte для HS256, *rsa.PrivateKey или *ecdsa.PrivateKey
+func signToken(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("sign RS256: %v", err)
+		}
+		signature = sig
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("sign ES256: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + b64(signature)
+}
+
+func testClaims(issuer string, overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": "user-1", "iss": issuer, "aud": "antiplag",
+		"exp": time.Now().Add(time.Hour).Unix(), "preferred_username": "ivanov",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+	return claims
+}
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	return key
+}
+
+func TestJWTVerifierHS256(t *testing.T) {
+	v := newJWTVerifier("", "antiplag", "", testHMACSecret, "", "")
+	secret := []byte(testHMACSecret)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", signToken(t, "HS256", "", secret, testClaims("", nil)), nil},
+		{"wrong secret", signToken(t, "HS256", "", []byte("other"), testClaims("", nil)), errInvalidToken},
+		{"alg none", signToken(t, "none", "", []byte{}, testClaims("", nil)), errInvalidToken},
+		{"alg HS384", signToken(t, "HS384", "", secret, testClaims("", nil)), errInvalidToken},
+		{"expired", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), errTokenExpired},
+		{"expired within leeway", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()})), nil},
+		{"no exp", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"exp": nil})), errInvalidToken},
+		{"not yet valid", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"nbf": now.Add(5 * time.Minute).Unix()})), errInvalidToken},
+		{"nbf within leeway", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()})), nil},
+		{"audience array", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"aud": []string{"other", "antiplag"}})), nil},
+		{"wrong audience", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"aud": "other"})), errInvalidToken},
+		{"no audience", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"aud": nil})), errInvalidToken},
+		{"no subject", signToken(t, "HS256", "", secret, testClaims("", map[string]interface{}{"sub": nil})), errInvalidToken},
+		{"malformed", "not.a-token", errInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (p.Subject != "user-1" || p.Name != "ivanov" || p.Role != RoleStudent || p.Method != "bearer") {
+				t.Errorf("Verify = %+v", p)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierRoles(t *testing.T) {
+	v := newJWTVerifier("", "", "", testHMACSecret, "", "groups")
+	tests := []struct {
+		name  string
+		roles interface{}
+		want  Role
+	}{
+		{"none", nil, RoleStudent},
+		{"string", "teacher", RoleTeacher},
+		{"highest of array", []string{"teacher", "admin", "unknown"}, RoleAdmin},
+		{"unknown only", []string{"guest"}, RoleStudent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signToken(t, "HS256", "", []byte(testHMACSecret), testClaims("", map[string]interface{}{"groups": tt.roles}))
+			p, err := v.Verify(context.Background(), token)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if p.Role != tt.want {
+				t.Errorf("role = %q, want %q", p.Role, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierJWKS(t *testing.T) {
+	iss := newTestIssuer(t)
+	rsaSigner := rsaKey(t)
+	ecSigner, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	iss.publish("rsa-1", &rsaSigner.PublicKey)
+	iss.publish("ec-1", &ecSigner.PublicKey)
+
+	// Издатель с "/" на конце совпадает с iss без него; секрет HS256 не задан
+	v := newJWTVerifier(iss.server.URL+"/", "antiplag", "", "", "", "")
+	claims := testClaims(iss.server.URL, nil)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"RS256", signToken(t, "RS256", "rsa-1", rsaSigner, claims), nil},
+		{"ES256", signToken(t, "ES256", "ec-1", ecSigner, claims), nil},
+		{"RS256 with EC key", signToken(t, "RS256", "ec-1", rsaSigner, claims), errInvalidToken},
+		{"ES256 with RSA key", signToken(t, "ES256", "rsa-1", ecSigner, claims), errInvalidToken},
+		{"HS256 without secret", signToken(t, "HS256", "rsa-1", []byte(testHMACSecret), claims), errInvalidToken},
+		{"wrong key", signToken(t, "RS256", "rsa-1", rsaKey(t), claims), errInvalidToken},
+		{"wrong issuer", signToken(t, "RS256", "rsa-1", rsaSigner, testClaims("https://evil.example", nil)), errInvalidToken},
+		{"no issuer", signToken(t, "RS256", "rsa-1", rsaSigner, testClaims("", map[string]interface{}{"iss": nil})), errInvalidToken},
+		{"wrong audience", signToken(t, "RS256", "rsa-1", rsaSigner, testClaims(iss.server.URL, map[string]interface{}{"aud": "other"})), errInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if n := iss.fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+}
+
+func TestJWTVerifierRefetchesOnUnknownKid(t *testing.T) {
+	iss := newTestIssuer(t)
+	oldKey, newKey := rsaKey(t), rsaKey(t)
+	iss.publish("old", &oldKey.PublicKey)
+
+	v := newJWTVerifier(iss.server.URL, "", iss.server.URL+"/jwks", "", "", "")
+	claims := testClaims(iss.server.URL, nil)
+	if _, err := v.Verify(context.Background(), signToken(t, "RS256", "old", oldKey, claims)); err != nil {
+		t.Fatalf("Verify with published key: %v", err)
+	}
+
+	// Издатель сменил ключи: сразу после загрузки неизвестный kid не вызывает повторный запрос
+	iss.publish("new", &newKey.PublicKey)
+	rotated := signToken(t, "RS256", "new", newKey, claims)
+	if _, err := v.Verify(context.Background(), rotated); !errors.Is(err, errInvalidToken) {
+		t.Fatalf("Verify with unknown kid right after fetch = %v, want errInvalidToken", err)
+	}
+	if n := iss.fetches.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times within refresh wait, want 1", n)
+	}
+
+	// По прошествии jwksRefreshWait неизвестный kid перезагружает набор
+	v.mu.Lock()
+	v.fetchedAt = time.Now().Add(-jwksRefreshWait - time.Second)
+	v.mu.Unlock()
+	if _, err := v.Verify(context.Background(), rotated); err != nil {
+		t.Fatalf("Verify with rotated key: %v", err)
+	}
+	if n := iss.fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+
+	// Пока набор свежий, неизвестный kid больше не запрашивается
+	if _, err := v.Verify(context.Background(), signToken(t, "RS256", "missing", newKey, claims)); !errors.Is(err, errInvalidToken) {
+		t.Errorf("Verify with missing kid = %v, want errInvalidToken", err)
+	}
+	if n := iss.fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times after missing kid, want 2", n)
+	}
+}
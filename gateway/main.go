@@ -4,6 +4,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,13 +16,62 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("Invalid value for %s: %q, using %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		log.Printf("Invalid value for %s: %q, using %v", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 func main() {
 	fileStoringURL := getEnv("FILE_STORING_URL", "http://file_storing:8001")
 	fileAnalysisURL := getEnv("FILE_ANALYSIS_URL", "http://file_analysis:8002")
 
+	jobs := newJobQueue(fileAnalysisURL,
+		getEnvInt("JOB_WORKERS", 4),
+		getEnvInt("JOB_QUEUE_SIZE", 256),
+		time.Duration(getEnvInt("JOB_TTL_HOURS", 24))*time.Hour)
+
+	auth := newAuthenticator(getEnv("API_KEYS", ""), newJWTVerifier(
+		getEnv("OIDC_ISSUER", ""),
+		getEnv("OIDC_AUDIENCE", ""),
+		getEnv("OIDC_JWKS_URL", ""),
+		getEnv("JWT_HS256_SECRET", ""),
+		getEnv("OIDC_NAME_CLAIM", "preferred_username"),
+		getEnv("OIDC_ROLES_CLAIM", "roles"),
+	))
+
+	// Пороги должны совпадать с порогами file_analysis: по ним проверяются настройки заданий.
+	// Если они разойдутся, file_analysis не применит несогласованные пороги задания.
+	suspicious, similarity := getEnvFloat("SUSPICIOUS_THRESHOLD", 0.7), getEnvFloat("SIMILARITY_THRESHOLD", 0.85)
+	if suspicious > similarity {
+		log.Fatalf("SUSPICIOUS_THRESHOLD %v exceeds SIMILARITY_THRESHOLD %v", suspicious, similarity)
+	}
+	works, err := newWorkStore(getEnv("WORKS_FILE", "/data/works.json"), suspicious, similarity)
+	if err != nil {
+		log.Fatalf("Failed to load works: %v", err)
+	}
+	allowedOrigins := strings.Split(getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000"), ",")
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/submit", handleSubmit(fileStoringURL, fileAnalysisURL))
-	mux.HandleFunc("/api/works/", handleReports(fileAnalysisURL))
+	mux.HandleFunc("/api/submit", handleSubmit(fileStoringURL, jobs, works))
+	mux.HandleFunc("/api/jobs/", handleJobs(fileAnalysisURL, jobs, works))
+	mux.HandleFunc("/api/compare", handleCompare(fileAnalysisURL, works))
+	mux.HandleFunc("/api/works", handleWorks(fileAnalysisURL, works))
+	mux.HandleFunc("/api/works/", handleWorks(fileAnalysisURL, works))
 	mux.HandleFunc("/health", handleGatewayHealth)
 
 	server := &http.Server{
@@ -28,7 +79,7 @@ func main() {
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
-		Handler:      corsMiddleware(mux),
+		Handler:      corsMiddleware(allowedOrigins, authMiddleware(auth, mux)),
 	}
 
 	log.Println("gateway running at :8000")
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+type LatePolicy string
+
+const (
+	LateFlag   LatePolicy = "flag"
+	LateReject LatePolicy = "reject"
+)
+
+// Анализаторы, которые можно включать в настройках задания
+var knownAnalyzers = map[string]bool{
+	"embedding": true,
+	"lexical":   true,
+	"minhash":   true,
+	"wordcloud": true,
+	"code":      true,
+	"structure": true,
+}
+
+// Режим проверки: пусто — по расширению файла, text — всегда как текст, code — как исходный код
+var knownModes = map[string]bool{
+	"":     true,
+	"text": true,
+	"code": true,
+}
+
+var (
+	errWorkExists   = errors.New("work already exists")
+	errWorkNotFound = errors.New("work not found")
+	workIDPattern   = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// Work — задание; владелец (subject преподавателя) видит все отчёты по нему
+type Work struct {
+	ID                  string     `json:"id"`
+	Title               string     `json:"title"`
+	Owner               string     `json:"owner,omitempty"`
+	Deadline            *time.Time `json:"deadline,omitempty"`
+	LatePolicy          LatePolicy `json:"late_policy,omitempty"`
+	SuspiciousThreshold float64    `json:"suspicious_threshold,omitempty"`
+	SimilarityThreshold float64    `json:"similarity_threshold,omitempty"`
+	Analyzers           []string   `json:"analyzers,omitempty"`
+	Mode                string     `json:"mode,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+func (w Work) validate() error {
+	if !workIDPattern.MatchString(w.ID) {
+		return fmt.Errorf("id must be 1-64 characters of letters, digits, '-' or '_'")
+	}
+	if w.LatePolicy != LateFlag && w.LatePolicy != LateReject {
+		return fmt.Errorf("late_policy must be %q or %q", LateFlag, LateReject)
+	}
+	if w.SimilarityThreshold < 0 || w.SimilarityThreshold > 1 {
+		return fmt.Errorf("similarity_threshold must be between 0 and 1")
+	}
+	if w.SuspiciousThreshold < 0 || w.SuspiciousThreshold > 1 {
+		return fmt.Errorf("suspicious_threshold must be between 0 and 1")
+	}
+	for _, name := range w.Analyzers {
+		if !knownAnalyzers[name] {
+			return fmt.Errorf("unknown analyzer %q", name)
+		}
+	}
+	if !knownModes[w.Mode] {
+		return fmt.Errorf("mode must be %q or %q", "text", "code")
+	}
+	return nil
+}
+
+// workPatch — изменения задания из PUT; отсутствующие в теле поля остаются nil
+type workPatch struct {
+	Title               *string      `json:"title"`
+	Owner               *string      `json:"owner"`
+	Deadline            optionalTime `json:"deadline"`
+	LatePolicy          *LatePolicy  `json:"late_policy"`
+	SuspiciousThreshold *float64     `json:"suspicious_threshold"`
+	SimilarityThreshold *float64     `json:"similarity_threshold"`
+	Analyzers           *[]string    `json:"analyzers"`
+	Mode                *string      `json:"mode"`
+}
+
+// optionalTime отличает отсутствующий дедлайн от явного null, которым дедлайн снимается
+type optionalTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (t *optionalTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if string(data) == "null" {
+		t.Value = nil
+		return nil
+	}
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	t.Value = &value
+	return nil
+}
+
+func (p workPatch) apply(w *Work) {
+	if p.Title != nil {
+		w.Title = *p.Title
+	}
+	if p.Owner != nil {
+		w.Owner = *p.Owner
+	}
+	if p.Deadline.Set {
+		w.Deadline = p.Deadline.Value
+	}
+	if p.LatePolicy != nil {
+		w.LatePolicy = *p.LatePolicy
+	}
+	if p.SuspiciousThreshold != nil {
+		w.SuspiciousThreshold = *p.SuspiciousThreshold
+	}
+	if p.SimilarityThreshold != nil {
+		w.SimilarityThreshold = *p.SimilarityThreshold
+	}
+	if p.Analyzers != nil {
+		w.Analyzers = *p.Analyzers
+	}
+	if p.Mode != nil {
+		w.Mode = *p.Mode
+	}
+}
+
+// checkThresholds проверяет, что полоса "подозрительно" не начинается выше полосы "плагиат";
+// незаданный порог задания заменяется глобальным порогом file_analysis
+func (w Work) checkThresholds(suspicious, similarity float64) error {
+	if w.SuspiciousThreshold > 0 {
+		suspicious = w.SuspiciousThreshold
+	}
+	if w.SimilarityThreshold > 0 {
+		similarity = w.SimilarityThreshold
+	}
+	if suspicious > similarity {
+		return fmt.Errorf("suspicious_threshold (%g) must not exceed similarity_threshold (%g); unset thresholds default to the global ones", suspicious, similarity)
+	}
+	return nil
+}
+
+// IsLate — сдача после дедлайна
+func (w Work) IsLate(at time.Time) bool {
+	return w.Deadline != nil && at.After(*w.Deadline)
+}
+
+// public — представление задания для студентов, без владельца и настроек проверки
+func (w Work) public() Work {
+	return Work{
+		ID:         w.ID,
+		Title:      w.Title,
+		Deadline:   w.Deadline,
+		LatePolicy: w.LatePolicy,
+		CreatedAt:  w.CreatedAt,
+		UpdatedAt:  w.UpdatedAt,
+	}
+}
+
+// WorkStore хранит задания в JSON-файле
+type WorkStore struct {
+	mu    sync.RWMutex
+	path  string
+	works map[string]Work
+	// Глобальные пороги file_analysis, с которыми сверяются пороги заданий
+	suspicious float64
+	similarity float64
+}
+
+func newWorkStore(path string, suspicious, similarity float64) (*WorkStore, error) {
+	s := &WorkStore{path: path, works: make(map[string]Work), suspicious: suspicious, similarity: similarity}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read works file: %w", err)
+	}
+
+	var works []Work
+	if err := json.Unmarshal(data, &works); err != nil {
+		return nil, fmt.Errorf("failed to parse works file: %w", err)
+	}
+	// Задания, сохранённые до нынешних проверок или при других глобальных порогах, не
+	// отбрасываются — их сдачи должны приниматься, — но владельцу придётся исправить их при
+	// следующем изменении; несогласованные пороги file_analysis не применит
+	for _, work := range works {
+		if work.LatePolicy == "" {
+			work.LatePolicy = LateFlag
+		}
+		if err := work.validate(); err != nil {
+			log.Printf("Work %s is invalid: %v", work.ID, err)
+		} else if err := work.checkThresholds(suspicious, similarity); err != nil {
+			log.Printf("Work %s has inconsistent thresholds, file_analysis will use the global ones: %v", work.ID, err)
+		}
+		s.works[work.ID] = work
+	}
+	return s, nil
+}
+
+func (s *WorkStore) Get(id string) (Work, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	work, ok := s.works[id]
+	return work, ok
+}
+
+func (s *WorkStore) List() []Work {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	works := make([]Work, 0, len(s.works))
+	for _, work := range s.works {
+		works = append(works, work)
+	}
+	sort.Slice(works, func(i, j int) bool { return works[i].ID < works[j].ID })
+	return works
+}
+
+func (s *WorkStore) Create(work Work) (Work, error) {
+	if work.LatePolicy == "" {
+		work.LatePolicy = LateFlag
+	}
+	if err := work.validate(); err != nil {
+		return Work{}, err
+	}
+	if err := work.checkThresholds(s.suspicious, s.similarity); err != nil {
+		return Work{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.works[work.ID]; ok {
+		return Work{}, errWorkExists
+	}
+
+	work.CreatedAt = time.Now()
+	work.UpdatedAt = work.CreatedAt
+	s.works[work.ID] = work
+	if err := s.save(); err != nil {
+		delete(s.works, work.ID)
+		return Work{}, err
+	}
+	return work, nil
+}
+
+// Update применяет fn к копии задания и сохраняет результат
+func (s *WorkStore) Update(id string, fn func(*Work) error) (Work, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.works[id]
+	if !ok {
+		return Work{}, errWorkNotFound
+	}
+
+	work := old
+	work.Analyzers = append([]string(nil), old.Analyzers...)
+	if err := fn(&work); err != nil {
+		return Work{}, err
+	}
+	work.ID, work.CreatedAt, work.UpdatedAt = old.ID, old.CreatedAt, time.Now()
+	if err := work.validate(); err != nil {
+		return Work{}, err
+	}
+	if err := work.checkThresholds(s.suspicious, s.similarity); err != nil {
+		return Work{}, err
+	}
+
+	s.works[id] = work
+	if err := s.save(); err != nil {
+		s.works[id] = old
+		return Work{}, err
+	}
+	return work, nil
+}
+
+func (s *WorkStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.works[id]
+	if !ok {
+		return errWorkNotFound
+	}
+
+	delete(s.works, id)
+	if err := s.save(); err != nil {
+		s.works[id] = old
+		return err
+	}
+	return nil
+}
+
+// save записывает задания во временный файл и атомарно подменяет основной.
+// Вызывается под s.mu.
+func (s *WorkStore) save() error {
+	works := make([]Work, 0, len(s.works))
+	for _, work := range s.works {
+		works = append(works, work)
+	}
+	sort.Slice(works, func(i, j int) bool { return works[i].ID < works[j].ID })
+
+	data, err := json.MarshalIndent(works, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal works: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create works directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write works file: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
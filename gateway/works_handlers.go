@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var errOwnerChange = errors.New("only admins can change the owner of a work")
+
+// handleWorks обслуживает /api/works, /api/works/{id} и /api/works/{id}/reports
+func handleWorks(fileAnalysisURL string, works *WorkStore) http.HandlerFunc {
+	reports := handleReports(fileAnalysisURL, works)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/works"), "/"), "/")
+		switch {
+		case parts[0] == "":
+			handleWorkCollection(w, r, works)
+		case len(parts) == 1:
+			handleWorkItem(w, r, works, parts[0])
+		case len(parts) == 2 && parts[1] == "reports":
+			reports(w, r)
+		default:
+			http.Error(w, "Invalid request path", http.StatusBadRequest)
+		}
+	}
+}
+
+func handleWorkCollection(w http.ResponseWriter, r *http.Request, works *WorkStore) {
+	principal, _ := principalFrom(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		list := []Work{}
+		for _, work := range works.List() {
+			if canManageWork(principal, works, work.ID) {
+				list = append(list, work)
+			} else {
+				list = append(list, work.public())
+			}
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		if principal.Role != RoleTeacher && principal.Role != RoleAdmin {
+			forbidden(w, "only teachers and admins can create works")
+			return
+		}
+
+		var work Work
+		if err := json.NewDecoder(r.Body).Decode(&work); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if principal.Role != RoleAdmin || work.Owner == "" {
+			work.Owner = principal.Subject
+		}
+
+		created, err := works.Create(work)
+		if errors.Is(err, errWorkExists) {
+			writeJSONError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleWorkItem(w http.ResponseWriter, r *http.Request, works *WorkStore, id string) {
+	principal, _ := principalFrom(r.Context())
+
+	work, ok := works.Get(id)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, errWorkNotFound.Error())
+		return
+	}
+	manager := canManageWork(principal, works, id)
+
+	switch r.Method {
+	case http.MethodGet:
+		if !manager {
+			work = work.public()
+		}
+		writeJSON(w, http.StatusOK, work)
+
+	case http.MethodPut:
+		if !manager {
+			forbidden(w, "you do not own this work")
+			return
+		}
+
+		// Тело читается до блокировки хранилища; поля, отсутствующие в нём, сохраняют прежние значения
+		var patch workPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		updated, err := works.Update(id, func(work *Work) error {
+			owner := work.Owner
+			patch.apply(work)
+			if work.Owner != owner && principal.Role != RoleAdmin {
+				return errOwnerChange
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, errOwnerChange):
+			forbidden(w, err.Error())
+		case errors.Is(err, errWorkNotFound):
+			writeJSONError(w, http.StatusNotFound, err.Error())
+		case err != nil:
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, updated)
+		}
+
+	case http.MethodDelete:
+		if !manager {
+			forbidden(w, "you do not own this work")
+			return
+		}
+		if err := works.Delete(id); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkCheckThresholds(t *testing.T) {
+	tests := []struct {
+		name                   string
+		suspicious, similarity float64
+		ok                     bool
+	}{
+		{"globals", 0, 0, true},
+		{"both set", 0.6, 0.8, true},
+		{"equal", 0.8, 0.8, true},
+		{"inverted", 0.9, 0.8, false},
+		{"suspicious above global similarity", 0.9, 0, false},
+		{"similarity below global suspicious", 0, 0.6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Work{SuspiciousThreshold: tt.suspicious, SimilarityThreshold: tt.similarity}
+			if err := w.checkThresholds(0.7, 0.85); (err == nil) != tt.ok {
+				t.Errorf("checkThresholds = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestWorkStoreRejectsInconsistentThresholds(t *testing.T) {
+	works, err := newWorkStore(filepath.Join(t.TempDir(), "works.json"), 0.7, 0.85)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := works.Create(Work{ID: "w1", SuspiciousThreshold: 0.9}); err == nil {
+		t.Error("Create accepted suspicious_threshold above the global similarity threshold")
+	}
+	if _, err := works.Create(Work{ID: "w1", SimilarityThreshold: 0.9}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := works.Update("w1", func(w *Work) error {
+		w.SimilarityThreshold = 0.6
+		return nil
+	}); err == nil {
+		t.Error("Update accepted similarity_threshold below the global suspicious threshold")
+	}
+}
+
+// Задания, сохранённые до проверки порогов, загружаются, чтобы их сдачи принимались
+func TestWorkStoreLoadsLegacyWorks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "works.json")
+	data, _ := json.Marshal([]Work{{ID: "old", Title: "Old", SuspiciousThreshold: 0.95, SimilarityThreshold: 0.9}})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	works, err := newWorkStore(path, 0.7, 0.85)
+	if err != nil {
+		t.Fatalf("newWorkStore: %v", err)
+	}
+	work, ok := works.Get("old")
+	if !ok || work.LatePolicy != LateFlag {
+		t.Fatalf("legacy work not loaded: %+v", work)
+	}
+}